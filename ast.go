@@ -0,0 +1,155 @@
+package suzuitoql
+
+import "fmt"
+
+// The syntax tree of a query consists of the following nodes.
+//
+//	BinaryOp  X && Y, X || Y, X == Y, X contains Y, X + Y, ...
+//	UnaryOp   -X
+//	Not       !X, NOT X
+//	Call      Name(Args...)
+//	FieldTerm Field:Term
+//	FieldRef  Name.Name...
+//	Literal   "abc", 'abc', `abc`, 123, 1.5, true, false
+//
+// Parentheses do not have nodes. They only decide the shape of the tree.
+// Filter.Expr returns the tree of a filter, and Walk, Inspect and Rewrite traverse trees.
+
+// Pos is a position in a query source.
+type Pos struct {
+	Offset int // Byte offset, starting at 0
+	Line   int // Line number, starting at 1
+	Column int // Column number in characters, starting at 1
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Span is a range [Start, End) in a query source.
+type Span struct {
+	Start Pos
+	End   Pos
+}
+
+// Op is an operator.
+type Op string
+
+const (
+	OpAnd   Op = "&&"
+	OpOr    Op = "||"
+	OpMinus Op = "-"
+	OpNot   Op = "!"
+	OpEq    Op = "=="
+	OpNe    Op = "!="
+	OpLt    Op = "<"
+	OpLe    Op = "<="
+	OpGt    Op = ">"
+	OpGe    Op = ">="
+	OpAdd   Op = "+"
+	OpSub   Op = "-"
+	OpMul   Op = "*"
+	OpDiv   Op = "/"
+	OpMod   Op = "%"
+	// OpContains is true if a list field X has an element Y, or a string X has a substring Y
+	OpContains Op = "contains"
+)
+
+// IsComparison returns true if op is one of ==, !=, <, <=, >, >= and contains.
+func (op Op) IsComparison() bool {
+	switch op {
+	case OpEq, OpNe, OpLt, OpLe, OpGt, OpGe, OpContains:
+		return true
+	}
+	return false
+}
+
+// IsArithmetic returns true if op is one of binary +, -, *, / and %.
+func (op Op) IsArithmetic() bool {
+	switch op {
+	case OpAdd, OpSub, OpMul, OpDiv, OpMod:
+		return true
+	}
+	return false
+}
+
+// Node is a node of the syntax tree of a query.
+type Node interface {
+	// Span returns where the node is in the query.
+	Span() Span
+}
+
+// Expr is an expression node. All nodes of the syntax tree are expressions.
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// BinaryOp is a binary operation such as `X && Y`, `X < Y` or `X + Y`.
+type BinaryOp struct {
+	Loc Span
+	Op  Op   // Logical, comparison or arithmetic operator
+	X   Expr // Left operand
+	Y   Expr // Right operand
+}
+
+// UnaryOp is a unary operation such as `-X`.
+type UnaryOp struct {
+	Loc Span
+	Op  Op // OpMinus
+	X   Expr
+}
+
+// Not is a logical negation such as `!X` or `NOT X`.
+type Not struct {
+	Loc Span
+	X   Expr
+}
+
+// Call is a function call such as `Name(Args...)`.
+type Call struct {
+	Loc     Span
+	Name    string
+	NameLoc Span // Span of Name
+	Args    []Expr
+}
+
+// FieldTerm is a term qualified by a field of a record such as `title:"ゴーシュ"`.
+// The evaluator evaluates Term in the field. See FieldEvaluator.
+type FieldTerm struct {
+	Loc      Span
+	Field    string
+	FieldLoc Span     // Span of Field
+	Term     *Literal // String, int or float literal
+}
+
+// FieldRef is a reference to a field of a record such as `Author.Name`.
+// The evaluator returns the value of the field. See RecordEvaluator.
+type FieldRef struct {
+	Loc  Span
+	Path []string // Names separated by '.'
+}
+
+// Literal is a literal of string, int, float or bool.
+// A string literal in a place of bool is a term, which the Evaluator evaluates.
+type Literal struct {
+	Loc   Span
+	Raw   string // Literal as written in the query, such as `"a\tb"`. Empty if the node is not parsed.
+	Value Value  // Value of the literal, such as "a<TAB>b"
+}
+
+func (n *BinaryOp) Span() Span  { return n.Loc }
+func (n *UnaryOp) Span() Span   { return n.Loc }
+func (n *Not) Span() Span       { return n.Loc }
+func (n *Call) Span() Span      { return n.Loc }
+func (n *FieldTerm) Span() Span { return n.Loc }
+func (n *FieldRef) Span() Span  { return n.Loc }
+func (n *Literal) Span() Span   { return n.Loc }
+
+func (*BinaryOp) exprNode()  {}
+func (*UnaryOp) exprNode()   {}
+func (*Not) exprNode()       {}
+func (*Call) exprNode()      {}
+func (*FieldTerm) exprNode() {}
+func (*FieldRef) exprNode()  {}
+func (*Literal) exprNode()   {}
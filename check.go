@@ -0,0 +1,211 @@
+package suzuitoql
+
+import (
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// Schema declares what queries can refer to.
+type Schema struct {
+	Functions *FunctionRegistry
+	// Fields are names of fields which field terms can refer to.
+	Fields []string
+}
+
+// Compile generates a filter from a query after checking types of the query against schema.
+// All type errors found in the query are returned at once as ErrorList.
+func Compile(query string, schema *Schema, opts ...Option) (*Filter, error) {
+	o := newOptions(opts)
+	root, err := parseExpr(query, o.limits)
+	if err != nil {
+		return nil, xerrors.Errorf("Cannot ParseExpr : %w", err)
+	}
+	if schema == nil {
+		schema = &Schema{}
+	}
+	if err := checkLimits(root, o.limits); err != nil {
+		return nil, err
+	}
+	if err := Check(root, schema); err != nil {
+		return nil, err
+	}
+	o.functions = schema.Functions
+	return newFilter(query, root, o)
+}
+
+// Check checks types of the syntax tree of a query against schema.
+func Check(root Expr, schema *Schema) error {
+	c := checker{schema: schema}
+	c.checkBool(root, "")
+	if len(c.errs) > 0 {
+		return c.errs
+	}
+	return nil
+}
+
+type checker struct {
+	schema *Schema
+	errs   ErrorList
+}
+
+// checkBool checks node evaluated as bool by op.
+// Literals are terms, which the evaluator evaluates as bool.
+func (c *checker) checkBool(node Expr, op Op) {
+	t := c.check(node)
+	if t == "" || t == TypeBool || isTerm(node) {
+		return
+	}
+	c.errs = append(c.errs, &OperandTypeError{
+		Loc:    node.Span(),
+		Op:     string(op),
+		Actual: string(t),
+	})
+}
+
+// check returns the type of node. It returns "" if the type is unknown due to errors or fields.
+func (c *checker) check(node Expr) Type {
+	switch n := node.(type) {
+	case *Literal:
+		return n.Value.Type
+	case *UnaryOp:
+		t := c.check(n.X)
+		if t == "" || t == TypeInt || t == TypeFloat {
+			return t
+		}
+		c.errs = append(c.errs, &OperandTypeError{
+			Loc:    n.Loc,
+			Op:     string(n.Op),
+			Actual: string(t),
+		})
+		return ""
+	case *BinaryOp:
+		if n.Op.IsComparison() {
+			x := c.check(n.X)
+			y := c.check(n.Y)
+			if x != "" && y != "" && !isComparable(n.Op, x, y) {
+				c.errs = append(c.errs, &OperandTypeError{
+					Loc:    n.Loc,
+					Op:     string(n.Op),
+					Actual: operandTypes(x, y),
+				})
+			}
+			return TypeBool
+		}
+		if n.Op.IsArithmetic() {
+			x := c.check(n.X)
+			y := c.check(n.Y)
+			if x == "" || y == "" {
+				return ""
+			}
+			t := arithmeticType(x, y)
+			if t == "" {
+				c.errs = append(c.errs, &OperandTypeError{
+					Loc:    n.Loc,
+					Op:     string(n.Op),
+					Actual: operandTypes(x, y),
+				})
+			}
+			return t
+		}
+		c.checkBool(n.X, n.Op)
+		c.checkBool(n.Y, n.Op)
+		return TypeBool
+	case *Not:
+		c.checkBool(n.X, OpNot)
+		return TypeBool
+	case *Call:
+		return c.checkCall(n)
+	case *FieldTerm:
+		c.checkFieldTerm(n)
+		return TypeBool
+	case *FieldRef:
+		// The type of a field is known only when the evaluator returns its value
+		c.checkField(strings.Join(n.Path, "."), n.Loc)
+		return ""
+	}
+	c.errs = append(c.errs, &SyntaxError{Loc: node.Span(), Msg: "unsupported expression"})
+	return ""
+}
+
+func (c *checker) checkCall(n *Call) Type {
+	argTypes := make([]Type, len(n.Args))
+	for i, arg := range n.Args {
+		argTypes[i] = c.check(arg)
+	}
+	if n.Name == matchFunctionName {
+		if _, err := compileMatch(n); err != nil {
+			c.errs = append(c.errs, err)
+		}
+		return TypeBool
+	}
+	fn, exists := c.schema.Functions.Lookup(n.Name)
+	if !exists {
+		c.errs = append(c.errs, &UnknownFunctionError{
+			Loc:  n.NameLoc,
+			Name: n.Name,
+		})
+		return ""
+	}
+	if len(fn.Params) != len(n.Args) {
+		c.errs = append(c.errs, &ArgumentCountError{
+			Loc:      n.Loc,
+			Func:     n.Name,
+			Expected: len(fn.Params),
+			Actual:   len(n.Args),
+		})
+		return fn.Result
+	}
+	for i, t := range argTypes {
+		if t == "" {
+			continue
+		}
+		if _, ok := convertValue(Value{Type: t}, fn.Params[i]); !ok {
+			c.errs = append(c.errs, &ArgumentTypeError{
+				Loc:      n.Args[i].Span(),
+				Func:     n.Name,
+				Index:    i,
+				Expected: string(fn.Params[i]),
+				Actual:   string(t),
+			})
+		}
+	}
+	return fn.Result
+}
+
+func (c *checker) checkFieldTerm(n *FieldTerm) {
+	switch n.Term.Value.Type {
+	case TypeString, TypeInt, TypeFloat:
+	default:
+		c.errs = append(c.errs, &OperandTypeError{
+			Loc:    n.Term.Loc,
+			Op:     ":",
+			Actual: string(n.Term.Value.Type),
+		})
+	}
+	c.checkField(n.Field, n.FieldLoc)
+}
+
+// checkField checks that name at loc is declared in the schema.
+func (c *checker) checkField(name string, loc Span) {
+	for _, field := range c.schema.Fields {
+		if field == name {
+			return
+		}
+	}
+	c.errs = append(c.errs, &UnknownFieldError{
+		Loc:  loc,
+		Name: name,
+	})
+}
+
+// isTerm returns true if node is a literal, optionally negated.
+func isTerm(node Expr) bool {
+	switch n := node.(type) {
+	case *Literal:
+		return true
+	case *UnaryOp:
+		return n.Op == OpMinus && isTerm(n.X)
+	}
+	return false
+}
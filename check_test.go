@@ -0,0 +1,105 @@
+package suzuitoql
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+func TestCheck(t *testing.T) {
+	functions := newTestFunctions(t)
+	fns := []Function{
+		{
+			Name:   "Not",
+			Params: []Type{TypeString},
+			Result: TypeBool,
+			Call: func(evaluator Evaluator, args []Value) (Value, error) {
+				return BoolValue(true), nil
+			},
+		},
+		{
+			Name:   "Upper",
+			Params: []Type{TypeString},
+			Result: TypeString,
+			Call: func(evaluator Evaluator, args []Value) (Value, error) {
+				return StringValue(strings.ToUpper(args[0].Str)), nil
+			},
+		},
+		{
+			Name:   "Half",
+			Params: []Type{TypeFloat},
+			Result: TypeFloat,
+			Call: func(evaluator Evaluator, args []Value) (Value, error) {
+				return FloatValue(args[0].Float / 2), nil
+			},
+		},
+	}
+	for _, fn := range fns {
+		if err := functions.Register(fn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	schema := &Schema{Functions: functions, Fields: []string{"title", "year"}}
+	testCases := []struct {
+		query    string
+		expected []string
+	}{
+		{query: `Not("a") && Upper("b") == "B" && Half(1) < 0.6 && Match("^a") && -1`},
+		{query: `title:"a" && year > 1900 && title contains "a"`},
+		{query: `Not(1)`, expected: []string{"1:5: argument 1 of function 'Not' must be string but int given"}},
+		{query: `Not("a", "b") || Len()`, expected: []string{
+			"1:1: function 'Not' takes 1 argument(s) but 2 given",
+			"1:18: function 'Len' takes 1 argument(s) but 0 given",
+			"1:18: cannot apply || to int",
+		}},
+		{query: `Upper("a") && "b"`, expected: []string{"1:1: cannot apply && to string"}},
+		{query: `!Len("a") || Half(Len("a"))`, expected: []string{
+			"1:2: cannot apply ! to int",
+			"1:14: cannot apply || to float",
+		}},
+		{query: `-"a" || 1 + "b" || "c" < 1 || !Upper(1)`, expected: []string{
+			"1:1: cannot apply - to string",
+			"1:9: cannot apply + to int and string",
+			"1:20: cannot apply < to string and int",
+			"1:38: argument 1 of function 'Upper' must be string but int given",
+			"1:32: cannot apply ! to string",
+		}},
+		{query: `Match("(") && Zed()`, expected: []string{
+			"1:7: invalid regular expression \"(\": error parsing regexp: missing closing ): `(`",
+			"1:15: unknown function 'Zed'",
+		}},
+		{query: `Match("a", "b") || Match(title) || Match(-1)`, expected: []string{
+			"1:1: function 'Match' takes 1 argument(s) but 2 given",
+			"1:26: pattern of Match must be a string literal",
+			"1:42: pattern of Match must be a string literal",
+		}},
+		{query: `author:"a" || title.name == 1`, expected: []string{
+			"1:1: unknown field 'author'",
+			"1:15: unknown field 'title.name'",
+		}},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.query, func(t *testing.T) {
+			root, err := ParseExpr(tC.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = Check(root, schema)
+			var list ErrorList
+			if err != nil && !xerrors.As(err, &list) {
+				t.Fatalf("expected ErrorList but %T", err)
+			}
+			actual := make([]string, len(list))
+			for i, e := range list {
+				actual[i] = e.Error()
+			}
+			if strings.Join(actual, "\n") != strings.Join(tC.expected, "\n") {
+				t.Errorf("expected\n%s\nbut\n%s", strings.Join(tC.expected, "\n"), strings.Join(actual, "\n"))
+			}
+			if _, err := Compile(tC.query, schema); (err == nil) != (len(tC.expected) == 0) {
+				t.Errorf("expected Compile to fail as Check but %v", err)
+			}
+		})
+	}
+}
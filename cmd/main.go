@@ -1,68 +1,344 @@
 package main
 
 import (
+	"bufio"
+	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/suzuito/suzuitoql"
 	"github.com/suzuito/suzuitoql/evalimpl"
 )
 
+// Exit statuses are the same as grep.
+const (
+	exitMatched    = 0
+	exitNotMatched = 1
+	exitError      = 2
+)
+
+const usage = `Usage: %[1]s [OPTION]... QUERY [FILE]...
+       %[1]s [OPTION]... -f QUERY_FILE [FILE]...
+       %[1]s explain [-f QUERY_FILE] [QUERY] [TEXT]
+Print lines of FILEs which match QUERY.
+The explain command prints how QUERY is evaluated on TEXT, or standard input without TEXT.
+Directories are searched recursively. Without FILEs, or if FILE is -, read standard input.
+Each line matches QUERY if the line contains string terms which make QUERY true.
+
+Options:
+`
+
 func main() {
-	// main2()
-	main3()
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+type command struct {
+	filter      *suzuitoql.Filter
+	invert      bool
+	count       bool
+	lineNumber  bool
+	filesOnly   bool
+	color       bool
+	withName    bool
+	stdout      *bufio.Writer
+	stderr      io.Writer
+	matched     bool
+	failed      bool
+	evaluator   textEvaluator
+	programName string
+}
+
+// textEvaluator evaluates a text set by Init.
+type textEvaluator interface {
+	suzuitoql.Evaluator
+	Init(s string)
+}
+
+// newFunctions returns functions callable with the evaluator returned by newEvaluator.
+func newFunctions(normalize bool) *suzuitoql.FunctionRegistry {
+	if normalize {
+		return evalimpl.NewNormalizedTextFunctions()
+	}
+	return evalimpl.NewAhoCorasickFunctions()
+}
+
+// newEvaluator returns an evaluator of texts for filter.
+func newEvaluator(filter *suzuitoql.Filter, normalize bool) textEvaluator {
+	if normalize {
+		return evalimpl.NewEvaluatorNormalizedText(evalimpl.NormalizeAll)
+	}
+	return evalimpl.NewEvaluatorAhoCorasick(filter)
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	programName := filepath.Base(os.Args[0])
+	flags := flag.NewFlagSet(programName, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, usage, programName)
+		flags.PrintDefaults()
+	}
+	queryFile := flags.String("f", "", "read QUERY from `QUERY_FILE`")
+	invert := flags.Bool("v", false, "select non-matching lines")
+	count := flags.Bool("c", false, "print only the number of selected lines per file")
+	lineNumber := flags.Bool("n", false, "print line numbers")
+	filesOnly := flags.Bool("l", false, "print only names of files with selected lines")
+	color := flags.Bool("color", false, "highlight matched terms in selected lines")
+	normalize := flags.Bool("normalize", false, "ignore differences of width, case, kana, long vowels and dakuten")
+	explain := len(args) > 0 && args[0] == "explain"
+	if explain {
+		args = args[1:]
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	args = flags.Args()
+
+	var query string
+	if *queryFile != "" {
+		b, err := ioutil.ReadFile(*queryFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", programName, err)
+			return exitError
+		}
+		query = string(b)
+	} else {
+		if len(args) == 0 {
+			flags.Usage()
+			return exitError
+		}
+		query = args[0]
+		args = args[1:]
+	}
+	// Type errors of the query are reported before reading any input
+	filter, err := suzuitoql.Compile(query, &suzuitoql.Schema{Functions: newFunctions(*normalize)})
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: invalid query\n%s\n", programName, suzuitoql.FormatError(query, err))
+		return exitError
+	}
+	evaluator := newEvaluator(filter, *normalize)
+	if explain {
+		return runExplain(filter, evaluator, args, stdin, stdout, stderr, programName)
+	}
+
+	c := command{
+		filter:      filter,
+		invert:      *invert,
+		count:       *count,
+		lineNumber:  *lineNumber,
+		filesOnly:   *filesOnly,
+		evaluator:   evaluator,
+		color:       *color && !*invert,
+		stdout:      bufio.NewWriter(stdout),
+		stderr:      stderr,
+		programName: programName,
+	}
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+	c.withName = len(args) > 1
+	for _, arg := range args {
+		if arg == "-" {
+			c.searchReader("(standard input)", stdin)
+			continue
+		}
+		c.searchPath(arg)
+	}
+	if err := c.stdout.Flush(); err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", programName, err)
+		return exitError
+	}
+	switch {
+	case c.failed:
+		return exitError
+	case c.matched:
+		return exitMatched
+	}
+	return exitNotMatched
 }
 
-// func main2() {
-// 	body, err := ioutil.ReadFile("c.txt")
-// 	if err != nil {
-// 		fmt.Fprintf(os.Stderr, "%+v\n", err)
-// 		os.Exit(1)
-// 	}
-// 	filter, err := suzuitoql.GenerateFilterFromString(string(body))
-// 	if err != nil {
-// 		fmt.Fprintf(os.Stderr, "%+v\n", err)
-// 		os.Exit(1)
-// 	}
-// 	fmt.Printf("====\n")
-// 	result, err := filter.Eval(&EvaluatorImpl{})
-// 	if err != nil {
-// 		fmt.Fprintf(os.Stderr, "%+v\n", err)
-// 		os.Exit(1)
-// 	}
-// 	fmt.Println(result)
-// }
-
-func main3() {
-	all, err := ioutil.ReadFile("data/1.txt")
+// runExplain prints how filter is evaluated on the text in args or stdin.
+func runExplain(filter *suzuitoql.Filter, evaluator textEvaluator, args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer, programName string) int {
+	var text string
+	switch len(args) {
+	case 0:
+		b, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", programName, err)
+			return exitError
+		}
+		text = strings.TrimSuffix(string(b), "\n")
+	case 1:
+		text = args[0]
+	default:
+		fmt.Fprintf(stderr, "%s: explain takes at most 1 TEXT\n", programName)
+		return exitError
+	}
+	evaluator.Init(text)
+	explanation, err := filter.Explain(evaluator)
+	fmt.Fprint(stdout, explanation)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%+v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(stderr, "%s: %s\n", programName, suzuitoql.FormatError(filter.Source(), err))
+		return exitError
 	}
-	rows := strings.Split(string(all), "\n")
+	if explanation.Result {
+		return exitMatched
+	}
+	return exitNotMatched
+}
 
-	filter, err := suzuitoql.GenerateFilterFromString(`
-	("ゴーシュ" && "われわれは下手")
-	||
-	("ゴーシュ" && Not("ねずみ"))
-	`)
+func (c *command) errorf(format string, a ...interface{}) {
+	c.stdout.Flush()
+	fmt.Fprintf(c.stderr, "%s: "+format+"\n", append([]interface{}{c.programName}, a...)...)
+	c.failed = true
+}
+
+// searchPath searches a file, or files in a directory recursively.
+func (c *command) searchPath(path string) {
+	info, err := os.Stat(path)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%+v\n", err)
-		os.Exit(1)
+		c.errorf("%v", err)
+		return
+	}
+	if !info.IsDir() {
+		c.searchFile(path)
+		return
 	}
-	evaluator := evalimpl.EvaluatorText{}
-	for _, row := range rows {
-		evaluator.Init(row)
-		result, err := filter.Eval(&evaluator)
+	c.withName = true
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "%+v\n", err)
-			os.Exit(1)
+			c.errorf("%v", err)
+			return nil
 		}
-		if !result {
-			continue
+		if d.Type().IsRegular() {
+			c.searchFile(p)
+		}
+		return nil
+	})
+	if err != nil {
+		c.errorf("%v", err)
+	}
+}
+
+func (c *command) searchFile(path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		c.errorf("%v", err)
+		return
+	}
+	defer file.Close()
+	c.searchReader(path, file)
+}
+
+// searchReader prints lines of r selected by the filter.
+func (c *command) searchReader(name string, r io.Reader) {
+	reader := bufio.NewReader(r)
+	selected := 0
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			c.errorf("%s: %v", name, err)
+			return
+		}
+		if line == "" && err == io.EOF {
+			break
+		}
+		line = strings.TrimSuffix(line, "\n")
+		c.evaluator.Init(line)
+		result, ranges, evalErr := c.eval()
+		if evalErr != nil {
+			// Like grep, an error on a line is reported and the rest of lines are searched
+			c.errorf("%s:%d: %v", name, lineNumber, evalErr)
+		} else if result != c.invert {
+			selected++
+			c.matched = true
+			if c.filesOnly {
+				break
+			}
+			if !c.count {
+				c.printLine(name, lineNumber, highlight(line, ranges))
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	switch {
+	case c.filesOnly:
+		if selected > 0 {
+			fmt.Fprintln(c.stdout, name)
+		}
+	case c.count:
+		if c.withName {
+			fmt.Fprintf(c.stdout, "%s:", name)
 		}
-		fmt.Printf("> %s\n", row)
+		fmt.Fprintln(c.stdout, selected)
+	}
+}
+
+// eval evaluates the line set to c.evaluator.
+// It returns ranges of matched terms in the line if c.color is true.
+func (c *command) eval() (bool, []suzuitoql.Range, error) {
+	if !c.color || c.count || c.filesOnly {
+		result, err := c.filter.Eval(c.evaluator)
+		return result, nil, err
+	}
+	m, err := c.filter.Match(c.evaluator)
+	if err != nil {
+		return false, nil, err
+	}
+	ranges := []suzuitoql.Range{}
+	for _, term := range m.Terms {
+		ranges = append(ranges, term.Ranges...)
+	}
+	return m.Result, ranges, nil
+}
+
+// Escape sequences for highlighting are the same as grep.
+const (
+	colorMatch = "\x1b[01;31m\x1b[K"
+	colorReset = "\x1b[m\x1b[K"
+)
+
+// highlight returns line whose ranges are colored. Overlapping ranges are merged.
+func highlight(line string, ranges []suzuitoql.Range) string {
+	if len(ranges) == 0 {
+		return line
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Start < ranges[j].Start
+	})
+	b := strings.Builder{}
+	offset := 0
+	for i := 0; i < len(ranges); {
+		start, end := ranges[i].Start, ranges[i].End
+		for i++; i < len(ranges) && ranges[i].Start <= end; i++ {
+			if ranges[i].End > end {
+				end = ranges[i].End
+			}
+		}
+		b.WriteString(line[offset:start])
+		b.WriteString(colorMatch)
+		b.WriteString(line[start:end])
+		b.WriteString(colorReset)
+		offset = end
+	}
+	b.WriteString(line[offset:])
+	return b.String()
+}
+
+func (c *command) printLine(name string, lineNumber int, line string) {
+	if c.withName {
+		fmt.Fprintf(c.stdout, "%s:", name)
+	}
+	if c.lineNumber {
+		fmt.Fprintf(c.stdout, "%d:", lineNumber)
 	}
+	fmt.Fprintln(c.stdout, line)
 }
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// chdirToFiles changes the working directory to a temporary directory with files for tests.
+func chdirToFiles(t *testing.T) {
+	files := map[string]string{
+		"a.txt":       "ゴーシュ\nねずみ\nセロ ゴーシュ\n",
+		"dir/b.txt":   "かっこう\nゴーシュ",
+		"dir/c/d.txt": "たぬきの子\n",
+		"query.txt":   "\"ねずみ\" ||\n\"たぬき\"\n",
+	}
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(wd)
+	})
+}
+
+// durations are durations of evaluation in outputs of explain.
+var durations = regexp.MustCompile(` \([^)]*s\)\n`)
+
+func TestRun(t *testing.T) {
+	chdirToFiles(t)
+	testCases := []struct {
+		desc     string
+		args     []string
+		stdin    string
+		expected string
+		// expectedStderr is a part of the standard error. The standard error must be empty if it is empty.
+		expectedStderr string
+		expectedStatus int
+	}{
+		{
+			desc:           "standard input",
+			args:           []string{`"ゴーシュ"`},
+			stdin:          "セロ\nゴーシュ\n",
+			expected:       "ゴーシュ\n",
+			expectedStatus: exitMatched,
+		},
+		{
+			desc:           "standard input by -",
+			args:           []string{"-n", `"ゴーシュ"`, "-"},
+			stdin:          "セロ\nゴーシュ",
+			expected:       "2:ゴーシュ\n",
+			expectedStatus: exitMatched,
+		},
+		{
+			desc:           "no lines selected",
+			args:           []string{`"ゴーシュ" && "ねずみ"`, "a.txt"},
+			expected:       "",
+			expectedStatus: exitNotMatched,
+		},
+		{
+			desc:           "-v",
+			args:           []string{"-v", `"ゴーシュ"`, "a.txt"},
+			expected:       "ねずみ\n",
+			expectedStatus: exitMatched,
+		},
+		{
+			desc:           "-c",
+			args:           []string{"-c", `"ゴーシュ"`, "a.txt"},
+			expected:       "2\n",
+			expectedStatus: exitMatched,
+		},
+		{
+			desc:           "-c with files",
+			args:           []string{"-c", "-v", `"ゴーシュ"`, "a.txt", "dir/b.txt"},
+			expected:       "a.txt:1\ndir/b.txt:1\n",
+			expectedStatus: exitMatched,
+		},
+		{
+			desc:           "-c without selected lines",
+			args:           []string{"-c", `"ねずみ" && "セロ"`, "a.txt"},
+			expected:       "0\n",
+			expectedStatus: exitNotMatched,
+		},
+		{
+			desc:           "-n",
+			args:           []string{"-n", `"ゴーシュ"`, "a.txt"},
+			expected:       "1:ゴーシュ\n3:セロ ゴーシュ\n",
+			expectedStatus: exitMatched,
+		},
+		{
+			desc:           "-l",
+			args:           []string{"-l", `"ゴーシュ" || "たぬき"`, "a.txt", "dir"},
+			expected:       "a.txt\ndir/b.txt\ndir/c/d.txt\n",
+			expectedStatus: exitMatched,
+		},
+		{
+			desc:           "directory",
+			args:           []string{"-n", `"ゴーシュ"`, "dir"},
+			expected:       "dir/b.txt:2:ゴーシュ\n",
+			expectedStatus: exitMatched,
+		},
+		{
+			desc:           "missing file",
+			args:           []string{`"ねずみ"`, "missing.txt", "a.txt"},
+			expected:       "a.txt:ねずみ\n",
+			expectedStderr: "stat missing.txt: no such file or directory\n",
+			expectedStatus: exitError,
+		},
+		{
+			desc:           "errors of evaluation do not stop searching",
+			args:           []string{"-n", `"ゴーシュ" || 1 / 0 > 0`, "a.txt"},
+			expected:       "1:ゴーシュ\n3:セロ ゴーシュ\n",
+			expectedStderr: "a.txt:2: 1:11: division by zero in /\n",
+			expectedStatus: exitError,
+		},
+		{
+			desc:           "unknown function",
+			args:           []string{`"ゴーシュ" || Nott("ねずみ")`, "a.txt"},
+			expectedStderr: "invalid query\n1:11: unknown function 'Nott'\n\"ゴーシュ\" || Nott(\"ねずみ\")\n              ^^^^\n",
+			expectedStatus: exitError,
+		},
+		{
+			desc:           "type error",
+			args:           []string{`Not(1)`, "a.txt"},
+			expectedStderr: "invalid query\n1:5: argument 1 of function 'Not' must be string but int given\nNot(1)\n    ^\n",
+			expectedStatus: exitError,
+		},
+		{
+			desc:           "--color",
+			args:           []string{"--color", `"ゴー" && "ーシュ" && !"ねずみ" || "セロ"`, "a.txt"},
+			expected:       "\x1b[01;31m\x1b[Kゴーシュ\x1b[m\x1b[K\nセロ \x1b[01;31m\x1b[Kゴーシュ\x1b[m\x1b[K\n",
+			expectedStatus: exitMatched,
+		},
+		{
+			desc:           "--color with Match",
+			args:           []string{"--color", `Match("ゴー+シュ") && !"ねずみ"`, "a.txt"},
+			expected:       "\x1b[01;31m\x1b[Kゴーシュ\x1b[m\x1b[K\nセロ \x1b[01;31m\x1b[Kゴーシュ\x1b[m\x1b[K\n",
+			expectedStatus: exitMatched,
+		},
+		{
+			desc:           "--color with -v",
+			args:           []string{"--color", "-v", `"ゴーシュ"`, "a.txt"},
+			expected:       "ねずみ\n",
+			expectedStatus: exitMatched,
+		},
+		{
+			desc:           "-f",
+			args:           []string{"-n", "-f", "query.txt", "a.txt", "dir"},
+			expected:       "a.txt:2:ねずみ\ndir/c/d.txt:1:たぬきの子\n",
+			expectedStatus: exitMatched,
+		},
+		{
+			desc:           "missing query file",
+			args:           []string{"-f", "missing.txt", "a.txt"},
+			expectedStderr: "open missing.txt: no such file or directory\n",
+			expectedStatus: exitError,
+		},
+		{
+			desc:           "explain",
+			args:           []string{"explain", `"ゴーシュ" && !"ねずみ"`, "ゴーシュはセロ弾きです"},
+			expected:       "&& => true\n  \"ゴーシュ\" => true\n  ! => true\n    \"ねずみ\" => false\n",
+			expectedStatus: exitMatched,
+		},
+		{
+			desc:           "explain standard input",
+			args:           []string{"explain", "-f", "query.txt"},
+			stdin:          "ゴーシュ\n",
+			expected:       "|| => false\n  \"ねずみ\" => false\n  \"たぬき\" => false\n",
+			expectedStatus: exitNotMatched,
+		},
+		{
+			desc:           "explain with texts",
+			args:           []string{"explain", `"ゴーシュ"`, "a", "b"},
+			expectedStderr: "explain takes at most 1 TEXT\n",
+			expectedStatus: exitError,
+		},
+		{
+			desc:           "invalid query",
+			args:           []string{`"ゴーシュ" &&`},
+			expectedStderr: "invalid query\n",
+			expectedStatus: exitError,
+		},
+		{
+			desc:           "no query",
+			args:           []string{"-n"},
+			expectedStderr: "Usage:",
+			expectedStatus: exitError,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			stdout := bytes.Buffer{}
+			stderr := bytes.Buffer{}
+			status := run(tC.args, strings.NewReader(tC.stdin), &stdout, &stderr)
+			if status != tC.expectedStatus {
+				t.Errorf("expected status %d but %d", tC.expectedStatus, status)
+			}
+			actual := stdout.String()
+			if tC.args[0] == "explain" {
+				actual = durations.ReplaceAllString(actual, "\n")
+			}
+			if actual != tC.expected {
+				t.Errorf("expected\n%s\nbut\n%s", tC.expected, actual)
+			}
+			if tC.expectedStderr == "" && stderr.Len() > 0 || !strings.Contains(stderr.String(), tC.expectedStderr) {
+				t.Errorf("expected %q in the standard error but %q", tC.expectedStderr, stderr.String())
+			}
+		})
+	}
+}
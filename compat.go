@@ -0,0 +1,162 @@
+package suzuitoql
+
+import (
+	"fmt"
+	"go/ast"
+	gotoken "go/token"
+
+	"golang.org/x/xerrors"
+)
+
+// GenerateFilter generates a filter from an expression parsed by go/parser.
+// source must be the source given to go/parser. Prefer GenerateFilterFromString.
+func GenerateFilter(source []byte, root ast.Expr, opts ...Option) (*Filter, error) {
+	c := goASTConverter{source: source}
+	expr, err := c.convert(root)
+	if err != nil {
+		return nil, xerrors.Errorf(": %w", err)
+	}
+	return newFilter(string(source), expr, newOptions(opts))
+}
+
+type goASTConverter struct {
+	source []byte
+}
+
+func (c *goASTConverter) pos(p gotoken.Pos) Pos {
+	offset := int(p) - 1
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(c.source) {
+		offset = len(c.source)
+	}
+	r := Pos{Offset: offset, Line: 1, Column: 1}
+	for _, ch := range string(c.source[:offset]) {
+		if ch == '\n' {
+			r.Line++
+			r.Column = 1
+			continue
+		}
+		r.Column++
+	}
+	return r
+}
+
+func (c *goASTConverter) span(n ast.Node) Span {
+	return Span{Start: c.pos(n.Pos()), End: c.pos(n.End())}
+}
+
+func (c *goASTConverter) errorf(n ast.Node, format string, args ...interface{}) error {
+	return &SyntaxError{
+		Loc: c.span(n),
+		Msg: fmt.Sprintf(format, args...),
+	}
+}
+
+func (c *goASTConverter) convert(node ast.Expr) (Expr, error) {
+	switch n := node.(type) {
+	case *ast.ParenExpr:
+		return c.convert(n.X)
+	case *ast.BinaryExpr:
+		var op Op
+		switch n.Op {
+		case gotoken.LAND:
+			op = OpAnd
+		case gotoken.LOR:
+			op = OpOr
+		case gotoken.EQL:
+			op = OpEq
+		case gotoken.NEQ:
+			op = OpNe
+		case gotoken.LSS:
+			op = OpLt
+		case gotoken.LEQ:
+			op = OpLe
+		case gotoken.GTR:
+			op = OpGt
+		case gotoken.GEQ:
+			op = OpGe
+		case gotoken.ADD:
+			op = OpAdd
+		case gotoken.SUB:
+			op = OpSub
+		case gotoken.MUL:
+			op = OpMul
+		case gotoken.QUO:
+			op = OpDiv
+		case gotoken.REM:
+			op = OpMod
+		default:
+			return nil, c.errorf(n, "unsupported binary operator %s", n.Op)
+		}
+		x, err := c.convert(n.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := c.convert(n.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &BinaryOp{Loc: c.span(n), Op: op, X: x, Y: y}, nil
+	case *ast.UnaryExpr:
+		if n.Op == gotoken.NOT {
+			x, err := c.convert(n.X)
+			if err != nil {
+				return nil, err
+			}
+			return &Not{Loc: c.span(n), X: x}, nil
+		}
+		if n.Op != gotoken.SUB {
+			return nil, c.errorf(n, "unsupported unary operator %s", n.Op)
+		}
+		x, err := c.convert(n.X)
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryOp{Loc: c.span(n), Op: OpMinus, X: x}, nil
+	case *ast.CallExpr:
+		fun, ok := n.Fun.(*ast.Ident)
+		if !ok {
+			return nil, c.errorf(n.Fun, "function name must be an identifier")
+		}
+		args := []Expr{}
+		for _, arg := range n.Args {
+			a, err := c.convert(arg)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, a)
+		}
+		return &Call{Loc: c.span(n), Name: fun.Name, NameLoc: c.span(fun), Args: args}, nil
+	case *ast.BasicLit:
+		var kind tokenKind
+		switch n.Kind {
+		case gotoken.STRING:
+			kind = tokenString
+		case gotoken.INT:
+			kind = tokenInt
+		case gotoken.FLOAT:
+			kind = tokenFloat
+		default:
+			return nil, c.errorf(n, "unsupported literal %s", n.Value)
+		}
+		return newLiteral(&token{Kind: kind, Text: n.Value, Loc: c.span(n)})
+	case *ast.Ident:
+		if n.Name == "true" || n.Name == "false" {
+			return &Literal{Loc: c.span(n), Raw: n.Name, Value: BoolValue(n.Name == "true")}, nil
+		}
+		return &FieldRef{Loc: c.span(n), Path: []string{n.Name}}, nil
+	case *ast.SelectorExpr:
+		x, err := c.convert(n.X)
+		if err != nil {
+			return nil, err
+		}
+		ref, ok := x.(*FieldRef)
+		if !ok {
+			return nil, c.errorf(n.X, "expected field before '.'")
+		}
+		return &FieldRef{Loc: c.span(n), Path: append(ref.Path, n.Sel.Name)}, nil
+	}
+	return nil, c.errorf(node, "unsupported expression")
+}
@@ -0,0 +1,506 @@
+package suzuitoql
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// program is a filter compiled into a tree of closures, one closure per node.
+// Functions are looked up once when the program is compiled.
+// Evaluating a program allocates nothing unless it calls functions or fails.
+type program struct {
+	root   boolFunc
+	states sync.Pool
+}
+
+// evalState is the state of an evaluation, which is reused through program.states.
+type evalState struct {
+	ctx       context.Context
+	done      <-chan struct{}
+	evaluator Evaluator
+	calls     int
+	// args holds arguments of all function calls in the program.
+	// Each call uses its own part of args.
+	args []Value
+	// terms collects matched terms if recording is true. See Filter.Match.
+	recording bool
+	terms     []MatchedTerm
+	// tracer records the evaluation of nodes if the program is compiled for tracing. See Filter.Explain.
+	tracer *tracer
+}
+
+type boolFunc func(s *evalState) (bool, error)
+
+type valueFunc func(s *evalState) (Value, error)
+
+// newProgram compiles root. If tracing is true, the program records the evaluation of each node into evalState.tracer.
+func newProgram(root Expr, o options, regexps map[*Call]*regexp.Regexp, tracing bool) *program {
+	c := compiler{
+		opts:    o,
+		regexps: regexps,
+		tracing: tracing,
+		asFalse: o.functionErrorPolicy == FunctionErrorAsFalse || hasFieldRef(root),
+	}
+	p := &program{
+		root: c.compileBool(root),
+	}
+	argsSize := c.argsSize
+	p.states.New = func() interface{} {
+		return &evalState{
+			args: make([]Value, argsSize),
+		}
+	}
+	return p
+}
+
+func (p *program) eval(ctx context.Context, evaluator Evaluator) (bool, error) {
+	result, _, err := p.run(ctx, evaluator, false, nil)
+	return result, err
+}
+
+// run evaluates the program. It returns matched terms if recording is true.
+// t must be given if the program is compiled for tracing.
+func (p *program) run(ctx context.Context, evaluator Evaluator, recording bool, t *tracer) (bool, []MatchedTerm, error) {
+	s := p.states.Get().(*evalState)
+	s.ctx = ctx
+	s.done = ctx.Done()
+	s.evaluator = evaluator
+	s.calls = 0
+	s.recording = recording
+	s.tracer = t
+	result, err := p.root(s)
+	terms := s.terms
+	s.ctx = nil
+	s.done = nil
+	s.evaluator = nil
+	s.terms = nil
+	s.tracer = nil
+	p.states.Put(s)
+	return result, terms, err
+}
+
+// mark returns the number of terms matched so far.
+func (s *evalState) mark() int {
+	return len(s.terms)
+}
+
+// reset drops terms matched after mark.
+// Bool closures returning false drop their terms, because the terms did not make the result true.
+func (s *evalState) reset(mark int) {
+	if s.recording {
+		s.terms = s.terms[:mark]
+	}
+}
+
+// record records node which was evaluated as true. re is the pattern if node is a call of Match.
+func (s *evalState) record(node Expr, v Value, re *regexp.Regexp) {
+	if !s.recording {
+		return
+	}
+	term := MatchedTerm{
+		Node:  node,
+		Value: v,
+	}
+	_, field := node.(*FieldTerm)
+	if re != nil {
+		if locator, ok := s.evaluator.(RegexpLocator); ok {
+			term.Ranges = locator.LocateRegexp(re)
+		}
+	} else if locator, ok := s.evaluator.(Locator); ok && v.Type != TypeBool && !field {
+		term.Ranges = locator.Locate(v)
+	}
+	s.terms = append(s.terms, term)
+}
+
+// checkDone returns *CanceledError at node if the context of s is done.
+func (s *evalState) checkDone(node Expr) error {
+	if s.done == nil {
+		return nil
+	}
+	select {
+	case <-s.done:
+		return &CanceledError{
+			Loc: node.Span(),
+			Err: s.ctx.Err(),
+		}
+	default:
+	}
+	return nil
+}
+
+type compiler struct {
+	opts     options
+	regexps  map[*Call]*regexp.Regexp
+	argsSize int
+	tracing  bool
+	// asFalse is true if the program may return *falseError
+	asFalse bool
+}
+
+// traceBool makes f of node record its evaluation if the compiler is tracing.
+func (c *compiler) traceBool(node Expr, f boolFunc) boolFunc {
+	if !c.tracing {
+		return f
+	}
+	return func(s *evalState) (bool, error) {
+		e, start := s.tracer.enter(node)
+		r, err := f(s)
+		if err == nil && !e.Term {
+			e.Value, e.Result = BoolValue(r), r
+		}
+		s.tracer.leave(e, start, err)
+		return r, err
+	}
+}
+
+// traceValue makes f of node record its evaluation if the compiler is tracing.
+func (c *compiler) traceValue(node Expr, f valueFunc) valueFunc {
+	if !c.tracing {
+		return f
+	}
+	return func(s *evalState) (Value, error) {
+		e, start := s.tracer.enter(node)
+		v, err := f(s)
+		if err == nil {
+			e.Value = v
+		}
+		s.tracer.leave(e, start, err)
+		return v, err
+	}
+}
+
+// compileBool compiles node evaluated into bool in the same way as operands of && and ||.
+func (c *compiler) compileBool(node Expr) boolFunc {
+	f := c.compileBoolNode(node)
+	if c.asFalse {
+		f = falseOnError(f)
+	}
+	return c.traceBool(node, f)
+}
+
+// falseError is returned by a node which has no value, such as a failed function call under FunctionErrorAsFalse
+// and a field without values. It makes the nearest comparison or term containing the node evaluate to false,
+// whatever the type of the node is.
+type falseError struct {
+	node Expr
+	err  error
+}
+
+func (e *falseError) Error() string {
+	return fmt.Sprintf("%s (evaluated as false)", e.err)
+}
+
+func (e *falseError) Unwrap() error { return e.err }
+
+// falseOnError makes f evaluate to false if f returns *falseError.
+func falseOnError(f boolFunc) boolFunc {
+	return func(s *evalState) (bool, error) {
+		r, err := f(s)
+		if ferr, ok := err.(*falseError); ok {
+			if s.tracer != nil {
+				s.tracer.evaluatedAsFalse(ferr)
+			}
+			return false, nil
+		}
+		return r, err
+	}
+}
+
+func hasFieldRef(root Expr) bool {
+	found := false
+	Inspect(root, func(node Expr) bool {
+		if _, ok := node.(*FieldRef); ok {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+func (c *compiler) compileBoolNode(node Expr) boolFunc {
+	switch n := node.(type) {
+	case *BinaryOp:
+		switch {
+		case n.Op == OpAnd:
+			x, y := c.compileBool(n.X), c.compileBool(n.Y)
+			return func(s *evalState) (bool, error) {
+				mark := s.mark()
+				if r, err := x(s); err != nil || !r {
+					return false, err
+				}
+				r, err := y(s)
+				if !r {
+					s.reset(mark)
+				}
+				return r, err
+			}
+		case n.Op == OpOr:
+			x, y := c.compileBool(n.X), c.compileBool(n.Y)
+			return func(s *evalState) (bool, error) {
+				if r, err := x(s); err != nil || r {
+					return r, err
+				}
+				return y(s)
+			}
+		case n.Op.IsComparison():
+			return c.compileComparison(n)
+		}
+	case *Not:
+		x := c.compileBool(n.X)
+		return func(s *evalState) (bool, error) {
+			mark := s.mark()
+			r, err := x(s)
+			s.reset(mark)
+			return !r, err
+		}
+	case *FieldTerm:
+		return func(s *evalState) (bool, error) {
+			if err := s.checkDone(n); err != nil {
+				return false, err
+			}
+			if s.tracer != nil {
+				s.tracer.touch(n.Term, n.Term.Value)
+			}
+			r, err := evalFieldTerm(s.evaluator, n)
+			if r {
+				s.record(n, n.Term.Value, nil)
+			}
+			if err == nil && s.tracer != nil {
+				s.tracer.term(n, n.Term.Value, r)
+			}
+			return r, err
+		}
+	}
+	// node is a term, whose value is evaluated by the evaluator
+	v := c.compileValueNode(node)
+	if v == nil {
+		return func(s *evalState) (bool, error) {
+			return false, xerrors.Errorf("Unsupported node %T", node)
+		}
+	}
+	matchable := isMatchable(node)
+	var re *regexp.Regexp
+	if call, ok := node.(*Call); ok {
+		re = c.regexps[call]
+	}
+	return func(s *evalState) (bool, error) {
+		if err := s.checkDone(node); err != nil {
+			return false, err
+		}
+		r, err := v(s)
+		if err != nil {
+			return false, err
+		}
+		var result bool
+		switch r.Type {
+		case TypeBool:
+			result = r.Bool
+		case TypeFloat:
+			result, err = s.evaluator.EvalFloat(r.Float)
+		case TypeInt:
+			result, err = s.evaluator.EvalInt(r.Int)
+		case TypeString:
+			result, err = s.evaluator.EvalString(r.Str)
+		default:
+			return false, xerrors.Errorf("Cannot eval %s", r.Type)
+		}
+		if err != nil {
+			return false, &TermError{
+				Loc:   node.Span(),
+				Value: r,
+				Err:   err,
+			}
+		}
+		if result && matchable {
+			s.record(node, r, re)
+		}
+		if s.tracer != nil {
+			s.tracer.term(node, r, result)
+		}
+		return result, nil
+	}
+}
+
+// isMatchable returns true if node is reported as a matched term when it is true.
+func isMatchable(node Expr) bool {
+	switch node.(type) {
+	case *Literal, *Call:
+		return true
+	}
+	return false
+}
+
+func (c *compiler) compileComparison(n *BinaryOp) boolFunc {
+	if ref, ok := containsField(n); ok {
+		y := c.compileValue(n.Y)
+		return func(s *evalState) (bool, error) {
+			if s.tracer != nil {
+				// The field has no value, because the evaluator evaluates it with the value of Y
+				s.tracer.touch(ref, Value{})
+			}
+			v, err := y(s)
+			if err != nil {
+				return false, err
+			}
+			if err := s.checkDone(n); err != nil {
+				return false, err
+			}
+			return evalFieldContains(s.evaluator, ref, v)
+		}
+	}
+	x, y := c.compileValue(n.X), c.compileValue(n.Y)
+	return func(s *evalState) (bool, error) {
+		a, err := x(s)
+		if err != nil {
+			return false, err
+		}
+		b, err := y(s)
+		if err != nil {
+			return false, err
+		}
+		if !isComparable(n.Op, a.Type, b.Type) {
+			return false, &OperandTypeError{
+				Loc:    n.Loc,
+				Op:     string(n.Op),
+				Actual: operandTypes(a.Type, b.Type),
+			}
+		}
+		return compareValues(n.Op, a, b), nil
+	}
+}
+
+// compileValue compiles node evaluated into a value.
+func (c *compiler) compileValue(node Expr) valueFunc {
+	if v := c.compileValueNode(node); v != nil {
+		return c.traceValue(node, v)
+	}
+	// Terms in values are not matched terms, because they do not decide the result by themselves
+	b := c.compileBool(node)
+	return func(s *evalState) (Value, error) {
+		mark := s.mark()
+		r, err := b(s)
+		s.reset(mark)
+		if err != nil {
+			return Value{}, err
+		}
+		return BoolValue(r), nil
+	}
+}
+
+// compileValueNode compiles node which has a value by itself. It returns nil for nodes evaluated into bool.
+func (c *compiler) compileValueNode(node Expr) valueFunc {
+	switch n := node.(type) {
+	case *Literal:
+		v := n.Value
+		return func(s *evalState) (Value, error) {
+			return v, nil
+		}
+	case *UnaryOp:
+		x := c.compileValue(n.X)
+		return func(s *evalState) (Value, error) {
+			v, err := x(s)
+			if err != nil {
+				return Value{}, err
+			}
+			if !isNumericType(v.Type) {
+				return Value{}, &OperandTypeError{
+					Loc:    n.Loc,
+					Op:     string(n.Op),
+					Actual: string(v.Type),
+				}
+			}
+			r, err := negate(v)
+			if err != nil {
+				return Value{}, &ArithmeticError{
+					Loc: n.Loc,
+					Op:  string(n.Op),
+					Err: err,
+				}
+			}
+			return r, nil
+		}
+	case *BinaryOp:
+		if n.Op.IsArithmetic() {
+			return c.compileArithmetic(n)
+		}
+	case *Call:
+		return c.compileCall(n)
+	case *FieldRef:
+		return func(s *evalState) (Value, error) {
+			return evalFieldRef(s.evaluator, n)
+		}
+	}
+	return nil
+}
+
+func (c *compiler) compileArithmetic(n *BinaryOp) valueFunc {
+	x, y := c.compileValue(n.X), c.compileValue(n.Y)
+	return func(s *evalState) (Value, error) {
+		a, err := x(s)
+		if err != nil {
+			return Value{}, err
+		}
+		b, err := y(s)
+		if err != nil {
+			return Value{}, err
+		}
+		if arithmeticType(a.Type, b.Type) == "" {
+			return Value{}, &OperandTypeError{
+				Loc:    n.Loc,
+				Op:     string(n.Op),
+				Actual: operandTypes(a.Type, b.Type),
+			}
+		}
+		r, err := evalArithmetic(n.Op, a, b)
+		if err != nil {
+			return Value{}, &ArithmeticError{
+				Loc: n.Loc,
+				Op:  string(n.Op),
+				Err: err,
+			}
+		}
+		return r, nil
+	}
+}
+
+func (c *compiler) compileCall(n *Call) valueFunc {
+	args := make([]valueFunc, len(n.Args))
+	for i, arg := range n.Args {
+		args[i] = c.compileValue(arg)
+	}
+	start, end := c.argsSize, c.argsSize+len(n.Args)
+	c.argsSize = end
+	// The function exists, because newFilter checks functions before compiling
+	fn, _ := c.opts.functions.Lookup(n.Name)
+	re, isMatch := c.regexps[n]
+	policy := c.opts.functionErrorPolicy
+	max := c.opts.limits.MaxFunctionCalls
+	return func(s *evalState) (Value, error) {
+		values := s.args[start:end:end]
+		for i, arg := range args {
+			v, err := arg(s)
+			if err != nil {
+				return Value{}, err
+			}
+			values[i] = v
+		}
+		if err := s.checkDone(n); err != nil {
+			return Value{}, err
+		}
+		s.calls++
+		if max > 0 && s.calls > max {
+			return Value{}, &FunctionCallLimitError{
+				Loc: n.Loc,
+				Max: max,
+			}
+		}
+		if isMatch {
+			return callMatch(s.ctx, s.evaluator, n, re, policy)
+		}
+		return callFunction(s.ctx, s.evaluator, fn, n, values, policy)
+	}
+}
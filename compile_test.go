@@ -0,0 +1,259 @@
+package suzuitoql
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+// testEvaluator evaluates terms by whether text contains them.
+type testEvaluator struct {
+	text string
+}
+
+func (e *testEvaluator) EvalFloat(v float64) (bool, error) {
+	return e.EvalString(strconv.FormatFloat(v, 'f', -1, 64))
+}
+
+func (e *testEvaluator) EvalInt(v int64) (bool, error) {
+	return e.EvalString(strconv.FormatInt(v, 10))
+}
+
+func (e *testEvaluator) EvalString(v string) (bool, error) {
+	return strings.Contains(e.text, v), nil
+}
+
+func newTestFunctions(t testing.TB) *FunctionRegistry {
+	r := NewFunctionRegistry()
+	fns := []Function{
+		{
+			Name:   "Len",
+			Params: []Type{TypeString},
+			Result: TypeInt,
+			Call: func(evaluator Evaluator, args []Value) (Value, error) {
+				return IntValue(int64(len(args[0].Str))), nil
+			},
+		},
+		{
+			Name:   "HasPrefix",
+			Params: []Type{TypeString},
+			Result: TypeBool,
+			Call: func(evaluator Evaluator, args []Value) (Value, error) {
+				return BoolValue(strings.HasPrefix(evaluator.(*testEvaluator).text, args[0].Str)), nil
+			},
+		},
+		{
+			Name:   "Fail",
+			Params: []Type{},
+			Result: TypeBool,
+			Call: func(evaluator Evaluator, args []Value) (Value, error) {
+				return Value{}, xerrors.New("failed")
+			},
+		},
+		{
+			Name:   "FailInt",
+			Params: []Type{},
+			Result: TypeInt,
+			Call: func(evaluator Evaluator, args []Value) (Value, error) {
+				return Value{}, xerrors.New("failed")
+			},
+		},
+	}
+	for _, fn := range fns {
+		if err := r.Register(fn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+func TestEval(t *testing.T) {
+	texts := []string{"", "abc", "bcd", "1"}
+	testCases := []struct {
+		query    string
+		expected []string // Results or errors for each of texts
+	}{
+		{query: `"a"`, expected: []string{"false", "true", "false", "false"}},
+		{query: `"a" && "b" || !"c"`, expected: []string{"true", "true", "false", "true"}},
+		{query: `("a" || "b") && NOT ("c" && "d")`, expected: []string{"false", "true", "false", "false"}},
+		{query: `1 + 2 * 3 == 7 && 1.5 < 2`, expected: []string{"true", "true", "true", "true"}},
+		{query: `10 / 0 == 1`, expected: []string{"1:1: division by zero in /", "1:1: division by zero in /", "1:1: division by zero in /", "1:1: division by zero in /"}},
+		{query: `-(1 - 2) % 2 == 1 && "1"`, expected: []string{"false", "false", "false", "true"}},
+		{query: `5.5 % 2 == 1.5 && -7 % 2.5 == -2.0 && 7 % -2.5 == 2`, expected: []string{"true", "true", "true", "true"}},
+		{query: `1.5 % 0 == 1`, expected: []string{"1:1: division by zero in %", "1:1: division by zero in %", "1:1: division by zero in %", "1:1: division by zero in %"}},
+		{query: `-9223372036854775808 < -9223372036854775807 && "1"`, expected: []string{"false", "false", "false", "true"}},
+		{query: `-(-9223372036854775808) > 0`, expected: []string{"1:1: overflow in -", "1:1: overflow in -", "1:1: overflow in -", "1:1: overflow in -"}},
+		{query: `"a" < 1`, expected: []string{"1:1: cannot apply < to string and int", "1:1: cannot apply < to string and int", "1:1: cannot apply < to string and int", "1:1: cannot apply < to string and int"}},
+		{query: `Len("abc") > 2 && HasPrefix("ab")`, expected: []string{"false", "true", "false", "false"}},
+		{query: `HasPrefix(1)`, expected: []string{"1:11: argument 1 of function 'HasPrefix' must be string but int given", "1:11: argument 1 of function 'HasPrefix' must be string but int given", "1:11: argument 1 of function 'HasPrefix' must be string but int given", "1:11: argument 1 of function 'HasPrefix' must be string but int given"}},
+		{query: `Fail() || "a"`, expected: []string{"1:1: Fail() failed: failed", "1:1: Fail() failed: failed", "1:1: Fail() failed: failed", "1:1: Fail() failed: failed"}},
+		{query: `Len(Len("a"))`, expected: []string{"1:5: argument 1 of function 'Len' must be string but int given", "1:5: argument 1 of function 'Len' must be string but int given", "1:5: argument 1 of function 'Len' must be string but int given", "1:5: argument 1 of function 'Len' must be string but int given"}},
+		{query: `Len("a") + Len("bc")`, expected: []string{"false", "false", "false", "false"}},
+	}
+	for _, tC := range testCases {
+		f, err := GenerateFilterFromString(tC.query, WithFunctions(newTestFunctions(t)))
+		if err != nil {
+			t.Fatalf("%s: %v", tC.query, err)
+		}
+		for i, text := range texts {
+			r, err := f.Eval(&testEvaluator{text: text})
+			actual := strconv.FormatBool(r)
+			if err != nil {
+				actual = errorMessage(err)
+			}
+			if actual != tC.expected[i] {
+				t.Errorf("%s on %q: expected %s but %s", tC.query, text, tC.expected[i], actual)
+			}
+		}
+	}
+}
+
+func TestUnknownFunction(t *testing.T) {
+	testCases := []struct {
+		query       string
+		expectedErr string
+	}{
+		{query: `Unknown("a") || "a"`, expectedErr: "1:1: unknown function 'Unknown'"},
+		{query: `"x" && Unknown("a")`, expectedErr: "1:8: unknown function 'Unknown'"},
+		{query: `Len(Unknown())`, expectedErr: "1:5: unknown function 'Unknown'"},
+	}
+	for _, tC := range testCases {
+		_, err := GenerateFilterFromString(tC.query, WithFunctions(newTestFunctions(t)))
+		var uerr *UnknownFunctionError
+		if !xerrors.As(err, &uerr) || errorMessage(err) != tC.expectedErr {
+			t.Errorf("%s: expected error %q but %v", tC.query, tC.expectedErr, err)
+		}
+	}
+}
+
+// errorMessage returns the message of err without prefixes of wrapping.
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return strings.TrimPrefix(err.Error(), ": ")
+}
+
+func TestEvalDoesNotAllocate(t *testing.T) {
+	queries := []string{
+		`("ゴーシュ" && "われわれは下手") || ("ゴーシュ" && !"ねずみ")`,
+		`1 + 2 * 3 == 7 && "a" < "b" && -1.5 < 2`,
+	}
+	evaluator := &testEvaluator{text: "ゴーシュはねずみ"}
+	for _, query := range queries {
+		f, err := GenerateFilterFromString(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		allocs := testing.AllocsPerRun(100, func() {
+			if _, err := f.Eval(evaluator); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if allocs != 0 {
+			t.Errorf("%s: Eval allocates %v times", query, allocs)
+		}
+	}
+}
+
+// benchmarkEval evaluates query by the compiled program, or by the interpreter if interpret is true.
+func benchmarkEval(b *testing.B, query string, interpret bool) {
+	f, err := GenerateFilterFromString(query, WithFunctions(newTestFunctions(b)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := context.Background()
+	evaluator := &testEvaluator{text: "ゴーシュは町の活動写真館でセロを弾く係りでした。"}
+	eval := f.prog.eval
+	if interpret {
+		eval = newInterpreter(f).eval
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := f.evalWith(ctx, evaluator, eval); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+var benchmarkEvalQueries = []struct {
+	name  string
+	query string
+}{
+	{"Terms", `("ゴーシュ" && "われわれは下手") || ("ゴーシュ" && !"ねずみ")`},
+	{"Arithmetic", `1 + 2 * 3 == 7 && 10 % 3 == 1 && -1.5 < 2`},
+	{"Functions", `Len("ゴーシュ") > 3 && HasPrefix("ゴーシュ")`},
+}
+
+func BenchmarkEval(b *testing.B) {
+	for _, q := range benchmarkEvalQueries {
+		b.Run(q.name, func(b *testing.B) {
+			benchmarkEval(b, q.query, false)
+		})
+	}
+}
+
+// BenchmarkInterpret is the baseline of BenchmarkEval.
+func BenchmarkInterpret(b *testing.B) {
+	for _, q := range benchmarkEvalQueries {
+		b.Run(q.name, func(b *testing.B) {
+			benchmarkEval(b, q.query, true)
+		})
+	}
+}
+
+func TestFunctionErrorPolicy(t *testing.T) {
+	testCases := []struct {
+		query    string
+		abort    string
+		asFalse  string
+		explains string // Explanation of the failed call under FunctionErrorAsFalse
+	}{
+		{query: `Fail() || "a"`, abort: "1:1: Fail() failed: failed", asFalse: "true", explains: "Fail() => error: 1:1: Fail() failed: failed (evaluated as false)"},
+		{query: `!Fail()`, abort: "1:2: Fail() failed: failed", asFalse: "true", explains: "Fail() => error: 1:2: Fail() failed: failed (evaluated as false)"},
+		{query: `FailInt() > 100 || "a"`, abort: "1:1: FailInt() failed: failed", asFalse: "true", explains: "FailInt() => error: 1:1: FailInt() failed: failed (evaluated as false)"},
+		{query: `!(FailInt() + 1 < 100) && "a"`, abort: "1:3: FailInt() failed: failed", asFalse: "true", explains: "FailInt() => error: 1:3: FailInt() failed: failed (evaluated as false)"},
+		{query: `FailInt() || "x"`, abort: "1:1: FailInt() failed: failed", asFalse: "false", explains: "FailInt() => error: 1:1: FailInt() failed: failed (evaluated as false)"},
+		{query: `(FailInt() == 1) == false`, abort: "1:2: FailInt() failed: failed", asFalse: "true", explains: "FailInt() => error: 1:2: FailInt() failed: failed (evaluated as false)"},
+		{query: `Len(1) > 0 || "a"`, abort: "1:5: argument 1 of function 'Len' must be string but int given", asFalse: "1:5: argument 1 of function 'Len' must be string but int given"},
+	}
+	durations := regexp.MustCompile(` \([^)]*s\)\n`)
+	evaluator := &testEvaluator{text: "abc"}
+	for _, tC := range testCases {
+		t.Run(tC.query, func(t *testing.T) {
+			policies := []struct {
+				policy   FunctionErrorPolicy
+				expected string
+			}{
+				{policy: FunctionErrorAbort, expected: tC.abort},
+				{policy: FunctionErrorAsFalse, expected: tC.asFalse},
+			}
+			for _, p := range policies {
+				f, err := GenerateFilterFromString(tC.query, WithFunctions(newTestFunctions(t)), WithFunctionErrorPolicy(p.policy))
+				if err != nil {
+					t.Fatal(err)
+				}
+				r, err := f.Eval(evaluator)
+				actual := strconv.FormatBool(r)
+				if err != nil {
+					actual = errorMessage(err)
+				}
+				if actual != p.expected {
+					t.Errorf("policy %d: expected %s but %s", p.policy, p.expected, actual)
+				}
+				e, explainErr := f.Explain(evaluator)
+				if errorMessage(explainErr) != errorMessage(err) || e.Result != r {
+					t.Errorf("policy %d: expected (%v, %v) as Eval but (%v, %v)", p.policy, r, err, e.Result, explainErr)
+				}
+				if p.policy == FunctionErrorAsFalse && tC.explains != "" && !strings.Contains(durations.ReplaceAllString(e.String(), "\n"), tC.explains+"\n") {
+					t.Errorf("expected %q in\n%s", tC.explains, e)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package suzuitoql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SyntaxError is returned when a query is not valid suzuitoql.
+type SyntaxError struct {
+	Loc Span
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Loc.Start, e.Msg)
+}
+
+func (e *SyntaxError) Span() Span { return e.Loc }
+
+// UnknownFunctionError is returned when a query calls a function the evaluator does not have.
+type UnknownFunctionError struct {
+	Loc  Span
+	Name string
+}
+
+func (e *UnknownFunctionError) Error() string {
+	return fmt.Sprintf("%s: unknown function '%s'", e.Loc.Start, e.Name)
+}
+
+func (e *UnknownFunctionError) Span() Span { return e.Loc }
+
+// ArgumentCountError is returned when a function is called with a wrong number of arguments.
+type ArgumentCountError struct {
+	Loc      Span
+	Func     string
+	Expected int
+	Actual   int
+}
+
+func (e *ArgumentCountError) Error() string {
+	return fmt.Sprintf(
+		"%s: function '%s' takes %d argument(s) but %d given",
+		e.Loc.Start,
+		e.Func,
+		e.Expected,
+		e.Actual,
+	)
+}
+
+func (e *ArgumentCountError) Span() Span { return e.Loc }
+
+// ArgumentTypeError is returned when an argument of a function call has a wrong type.
+// Loc is the span of the argument.
+type ArgumentTypeError struct {
+	Loc      Span
+	Func     string
+	Index    int
+	Expected string
+	Actual   string
+}
+
+func (e *ArgumentTypeError) Error() string {
+	return fmt.Sprintf(
+		"%s: argument %d of function '%s' must be %s but %s given",
+		e.Loc.Start,
+		e.Index+1,
+		e.Func,
+		e.Expected,
+		e.Actual,
+	)
+}
+
+func (e *ArgumentTypeError) Span() Span { return e.Loc }
+
+// OperandTypeError is returned when an operand of an operator has a wrong type.
+// Op is empty when the whole query has a wrong type.
+type OperandTypeError struct {
+	Loc    Span
+	Op     string
+	Actual string
+}
+
+func (e *OperandTypeError) Error() string {
+	if e.Op == "" {
+		return fmt.Sprintf("%s: query must be bool but %s", e.Loc.Start, e.Actual)
+	}
+	return fmt.Sprintf("%s: cannot apply %s to %s", e.Loc.Start, e.Op, e.Actual)
+}
+
+func (e *OperandTypeError) Span() Span { return e.Loc }
+
+var (
+	ErrDivisionByZero = errors.New("division by zero")
+	ErrOverflow       = errors.New("overflow")
+)
+
+// ArithmeticError is returned when an arithmetic operation fails.
+// Err is ErrDivisionByZero or ErrOverflow.
+type ArithmeticError struct {
+	Loc Span
+	Op  string
+	Err error
+}
+
+func (e *ArithmeticError) Error() string {
+	return fmt.Sprintf("%s: %s in %s", e.Loc.Start, e.Err, e.Op)
+}
+
+func (e *ArithmeticError) Unwrap() error { return e.Err }
+
+func (e *ArithmeticError) Span() Span { return e.Loc }
+
+// CanceledError is returned when evaluation stops because its context is done.
+// Err is the error of the context. Loc is where the evaluation stopped.
+type CanceledError struct {
+	Loc Span
+	Err error
+}
+
+func (e *CanceledError) Error() string {
+	return fmt.Sprintf("%s: evaluation stopped: %s", e.Loc.Start, e.Err)
+}
+
+func (e *CanceledError) Unwrap() error { return e.Err }
+
+func (e *CanceledError) Span() Span { return e.Loc }
+
+// FunctionCallError is returned when a function call fails.
+// Err is the error returned by the function, if any.
+type FunctionCallError struct {
+	Loc  Span
+	Func string
+	Args []Value
+	Err  error
+}
+
+func (e *FunctionCallError) Error() string {
+	args := make([]string, len(e.Args))
+	for i, arg := range e.Args {
+		args[i] = arg.String()
+	}
+	return fmt.Sprintf("%s: %s(%s) failed: %s", e.Loc.Start, e.Func, strings.Join(args, ", "), e.Err)
+}
+
+func (e *FunctionCallError) Unwrap() error { return e.Err }
+
+func (e *FunctionCallError) Span() Span { return e.Loc }
+
+// TermError is returned when the evaluator fails to evaluate a term.
+// Err is the error returned by the evaluator.
+type TermError struct {
+	Loc   Span
+	Value Value
+	Err   error
+}
+
+func (e *TermError) Error() string {
+	return fmt.Sprintf("%s: cannot evaluate term %s: %s", e.Loc.Start, e.Value, e.Err)
+}
+
+func (e *TermError) Unwrap() error { return e.Err }
+
+func (e *TermError) Span() Span { return e.Loc }
+
+// ErrorList is a list of errors found in a query.
+type ErrorList []error
+
+func (e ErrorList) Error() string {
+	switch len(e) {
+	case 0:
+		return "no errors"
+	case 1:
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", e[0], len(e)-1)
+}
+
+type spanError interface {
+	error
+	Span() Span
+}
+
+// FormatError returns a message of err followed by the line of source where err occurred,
+// with the offending part underlined by carets.
+//
+//	1:8: unknown function 'Foo'
+//	"a" && Foo(1)
+//	       ^^^^^^
+//
+// If err is ErrorList, each error in it is formatted.
+func FormatError(source string, err error) string {
+	var list ErrorList
+	if errors.As(err, &list) {
+		r := make([]string, len(list))
+		for i, e := range list {
+			r[i] = FormatError(source, e)
+		}
+		return strings.Join(r, "\n")
+	}
+	var serr spanError
+	if !errors.As(err, &serr) {
+		return err.Error()
+	}
+	return serr.Error() + "\n" + Snippet(source, serr.Span())
+}
+
+// Snippet returns the line of source containing the start of span, followed by a line
+// underlining span with carets. A span over several lines is underlined to the end of its first line.
+func Snippet(source string, span Span) string {
+	start := span.Start.Offset
+	if start > len(source) {
+		start = len(source)
+	}
+	lineStart := strings.LastIndexByte(source[:start], '\n') + 1
+	lineEnd := len(source)
+	if i := strings.IndexByte(source[start:], '\n'); i >= 0 {
+		lineEnd = start + i
+	}
+	end := span.End.Offset
+	if end > lineEnd {
+		end = lineEnd
+	}
+	line := strings.TrimSuffix(source[lineStart:lineEnd], "\r")
+	if end > lineStart+len(line) {
+		end = lineStart + len(line)
+	}
+	b := strings.Builder{}
+	b.WriteString(line)
+	b.WriteString("\n")
+	for _, r := range source[lineStart:start] {
+		if r == '\t' {
+			b.WriteString("\t")
+			continue
+		}
+		b.WriteString(strings.Repeat(" ", runeWidth(r)))
+	}
+	width := 0
+	for _, r := range source[start:end] {
+		width += runeWidth(r)
+	}
+	if width == 0 {
+		width = 1
+	}
+	b.WriteString(strings.Repeat("^", width))
+	return b.String()
+}
+
+// runeWidth returns the number of columns r occupies in a terminal.
+func runeWidth(r rune) int {
+	switch {
+	case 0x1100 <= r && r <= 0x115F,
+		0x2E80 <= r && r <= 0xA4CF,
+		0xAC00 <= r && r <= 0xD7A3,
+		0xF900 <= r && r <= 0xFAFF,
+		0xFE30 <= r && r <= 0xFE4F,
+		0xFF00 <= r && r <= 0xFF60,
+		0xFFE0 <= r && r <= 0xFFE6,
+		0x20000 <= r && r <= 0x3FFFD:
+		return 2
+	}
+	return 1
+}
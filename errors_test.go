@@ -0,0 +1,143 @@
+package suzuitoql
+
+import (
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+func TestSnippet(t *testing.T) {
+	// span returns the span of the first substring s of source
+	span := func(source string, s string) Span {
+		l := newLexer(source)
+		for l.pos.Offset < len(source) && source[l.pos.Offset:l.pos.Offset+len(s)] != s {
+			l.advance()
+		}
+		return Span{Start: l.pos, End: posAfter(l.pos, s)}
+	}
+	testCases := []struct {
+		desc     string
+		source   string
+		target   string
+		expected string
+	}{
+		{
+			desc:     "ASCII",
+			source:   `"a" && Foo(1)`,
+			target:   `Foo(1)`,
+			expected: "\"a\" && Foo(1)\n       ^^^^^^",
+		},
+		{
+			desc:     "wide characters before and in the span",
+			source:   `"ゴーシュ" && セロ(1)`,
+			target:   `セロ(1)`,
+			expected: "\"ゴーシュ\" && セロ(1)\n              ^^^^^^^",
+		},
+		{
+			desc:     "tabs before the span",
+			source:   "\t\"a\" &&\t\"b\" < 1",
+			target:   `"b" < 1`,
+			expected: "\t\"a\" &&\t\"b\" < 1\n\t      \t^^^^^^^",
+		},
+		{
+			desc:     "span over lines",
+			source:   "\"a\" &&\n  (\"b\" ||\n   \"c\") && \"d\"",
+			target:   "(\"b\" ||\n   \"c\")",
+			expected: "  (\"b\" ||\n  ^^^^^^^",
+		},
+		{
+			desc:     "CRLF",
+			source:   "\"a\" &&\r\n\"b\" < 1\r\n",
+			target:   "\"b\" < 1\r\n",
+			expected: "\"b\" < 1\n^^^^^^^",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if actual := Snippet(tC.source, span(tC.source, tC.target)); actual != tC.expected {
+				t.Errorf("expected\n%s\nbut\n%s", tC.expected, actual)
+			}
+		})
+	}
+	// An empty span at the end of the source is underlined by a caret
+	end := posAfter(Pos{Line: 1, Column: 1}, `"a" &&`)
+	if actual := Snippet(`"a" &&`, Span{Start: end, End: end}); actual != "\"a\" &&\n      ^" {
+		t.Errorf("expected a caret after the source but\n%s", actual)
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	schema := &Schema{Functions: NewFunctionRegistry()}
+	testCases := []struct {
+		desc     string
+		query    string
+		expected string
+	}{
+		{
+			desc:  "syntax error",
+			query: `"ゴーシュ" && ("セロ" ||`,
+			expected: "1:19: expected operand, found EOF\n" +
+				"\"ゴーシュ\" && (\"セロ\" ||\n" +
+				"                        ^",
+		},
+		{
+			desc:  "list of errors",
+			query: "Foo(1) ||\n\t!Bar(\"ねずみ\")",
+			expected: "1:1: unknown function 'Foo'\n" +
+				"Foo(1) ||\n" +
+				"^^^\n" +
+				"2:3: unknown function 'Bar'\n" +
+				"\t!Bar(\"ねずみ\")\n" +
+				"\t ^^^",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			_, err := Compile(tC.query, schema)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if actual := FormatError(tC.query, err); actual != tC.expected {
+				t.Errorf("expected\n%s\nbut\n%s", tC.expected, actual)
+			}
+		})
+	}
+	err := xerrors.New("no span")
+	if actual := FormatError(`"a"`, err); actual != "no span" {
+		t.Errorf("expected the message of an error without span but %s", actual)
+	}
+}
+
+// failingEvaluator fails to evaluate the term fail.
+type failingEvaluator struct {
+	testEvaluator
+	fail string
+}
+
+var errBoom = xerrors.New("boom")
+
+func (e *failingEvaluator) EvalString(v string) (bool, error) {
+	if v == e.fail {
+		return false, errBoom
+	}
+	return e.testEvaluator.EvalString(v)
+}
+
+func TestFormatErrorOfEvaluation(t *testing.T) {
+	query := "\"a\" &&\n  \"bb\""
+	f, err := GenerateFilterFromString(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.Eval(&failingEvaluator{testEvaluator: testEvaluator{text: "a"}, fail: "bb"})
+	var terr *TermError
+	if !xerrors.As(err, &terr) || !xerrors.Is(err, errBoom) {
+		t.Fatalf("expected TermError wrapping boom but %v", err)
+	}
+	expected := "2:3: cannot evaluate term \"bb\": boom\n" +
+		"  \"bb\"\n" +
+		"  ^^^^"
+	if actual := FormatError(query, err); actual != expected {
+		t.Errorf("expected\n%s\nbut\n%s", expected, actual)
+	}
+}
@@ -0,0 +1,118 @@
+package evalimpl
+
+import (
+	"sort"
+)
+
+// ahoCorasick is an Aho-Corasick automaton, which finds multiple patterns in a text by a single scan.
+type ahoCorasick struct {
+	states []acState
+}
+
+type acState struct {
+	// edges are transitions sorted by label
+	edges []acEdge
+	fail  int32
+	// output is the nearest state on the fail chain, including itself, which ends patterns.
+	// It is -1 if there is no such state.
+	output int32
+	// patterns are IDs of patterns ending at the state
+	patterns []int32
+}
+
+type acEdge struct {
+	label byte
+	next  int32
+}
+
+func newAhoCorasick(patterns []string) *ahoCorasick {
+	a := &ahoCorasick{
+		states: []acState{{}},
+	}
+	for id, pattern := range patterns {
+		s := int32(0)
+		for i := 0; i < len(pattern); i++ {
+			next, ok := a.next(s, pattern[i])
+			if !ok {
+				next = int32(len(a.states))
+				a.states = append(a.states, acState{})
+				a.states[s].edges = insertEdge(a.states[s].edges, acEdge{label: pattern[i], next: next})
+			}
+			s = next
+		}
+		a.states[s].patterns = append(a.states[s].patterns, int32(id))
+	}
+	// Fail links are computed in breadth-first order
+	a.states[0].output = -1
+	if len(a.states[0].patterns) > 0 {
+		a.states[0].output = 0
+	}
+	queue := []int32{0}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		for _, e := range a.states[s].edges {
+			child := &a.states[e.next]
+			if s != 0 {
+				child.fail = a.transition(a.states[s].fail, e.label)
+			}
+			child.output = a.states[child.fail].output
+			if len(child.patterns) > 0 {
+				child.output = e.next
+			}
+			queue = append(queue, e.next)
+		}
+	}
+	return a
+}
+
+// insertEdge inserts e into edges keeping them sorted by label.
+func insertEdge(edges []acEdge, e acEdge) []acEdge {
+	i := sort.Search(len(edges), func(i int) bool {
+		return edges[i].label >= e.label
+	})
+	edges = append(edges, acEdge{})
+	copy(edges[i+1:], edges[i:])
+	edges[i] = e
+	return edges
+}
+
+func (a *ahoCorasick) next(s int32, label byte) (int32, bool) {
+	edges := a.states[s].edges
+	i := sort.Search(len(edges), func(i int) bool {
+		return edges[i].label >= label
+	})
+	if i < len(edges) && edges[i].label == label {
+		return edges[i].next, true
+	}
+	return 0, false
+}
+
+// transition returns the state after reading label at s, following fail links.
+func (a *ahoCorasick) transition(s int32, label byte) int32 {
+	for {
+		if next, ok := a.next(s, label); ok {
+			return next
+		}
+		if s == 0 {
+			return 0
+		}
+		s = a.states[s].fail
+	}
+}
+
+// scan calls found with IDs of all patterns found in text.
+func (a *ahoCorasick) scan(text string, found func(id int32)) {
+	s := int32(0)
+	for i := 0; i < len(text); i++ {
+		s = a.transition(s, text[i])
+		for o := a.states[s].output; o >= 0; o = a.states[a.states[o].fail].output {
+			for _, id := range a.states[o].patterns {
+				found(id)
+			}
+			if o == 0 {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package evalimpl
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/suzuito/suzuitoql"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalization is a set of normalizations applied to texts and terms before matching.
+type Normalization uint
+
+const (
+	// NormalizeNFKC applies Unicode NFKC, which unifies full-width and half-width characters,
+	// such as "ＡＢＣ" into "ABC" and "ｺﾞｰｼｭ" into "ゴーシュ".
+	NormalizeNFKC Normalization = 1 << iota
+	// NormalizeCase folds cases, such as "ABC" into "abc".
+	NormalizeCase
+	// NormalizeKana folds katakana into hiragana, such as "ゴーシュ" into "ごーしゅ".
+	NormalizeKana
+	// NormalizeLongVowel removes long vowel marks after kana, such as "セロー" into "セロ".
+	NormalizeLongVowel
+	// NormalizeDakuten removes dakuten and handakuten, such as "ゴーシュ" into "コーシュ".
+	NormalizeDakuten
+
+	// NormalizeAll applies all normalizations.
+	NormalizeAll = NormalizeNFKC | NormalizeCase | NormalizeKana | NormalizeLongVowel | NormalizeDakuten
+)
+
+// Normalize returns s normalized by n.
+func Normalize(s string, n Normalization) string {
+	return newNormalizedText(s, n).text
+}
+
+// normalizedText is a text normalized segment by segment.
+// The bytes of the text at i come from the bytes of the original text at [starts[i], ends[i]).
+type normalizedText struct {
+	text   string
+	starts []int
+	ends   []int
+}
+
+var caser = cases.Fold()
+
+func newNormalizedText(s string, n Normalization) normalizedText {
+	b := strings.Builder{}
+	starts := make([]int, 0, len(s))
+	ends := make([]int, 0, len(s))
+	var it norm.Iter
+	if n&NormalizeNFKC != 0 {
+		it.InitString(norm.NFKC, s)
+	}
+	var last rune
+	for offset := 0; offset < len(s); {
+		// A segment is a character with its combining characters
+		var seg string
+		start := offset
+		if n&NormalizeNFKC != 0 {
+			seg = string(it.Next())
+			offset = it.Pos()
+		} else {
+			_, size := utf8.DecodeRuneInString(s[offset:])
+			seg = s[offset : offset+size]
+			offset += size
+		}
+		seg = normalizeSegment(seg, n, last)
+		for i := 0; i < len(seg); i++ {
+			starts = append(starts, start)
+			ends = append(ends, offset)
+		}
+		b.WriteString(seg)
+		if r, _ := utf8.DecodeLastRuneInString(seg); r != utf8.RuneError {
+			last = r
+		}
+	}
+	return normalizedText{
+		text:   b.String(),
+		starts: starts,
+		ends:   ends,
+	}
+}
+
+// normalizeSegment normalizes seg other than NFKC. last is the last rune before seg.
+func normalizeSegment(seg string, n Normalization, last rune) string {
+	if n&NormalizeCase != 0 {
+		seg = caser.String(seg)
+	}
+	if n&NormalizeDakuten != 0 {
+		seg = norm.NFC.String(strings.Map(func(r rune) rune {
+			switch r {
+			case '゙', '゚', '゛', '゜', 'ﾞ', 'ﾟ':
+				return -1
+			}
+			return r
+		}, norm.NFD.String(seg)))
+	}
+	if n&NormalizeKana != 0 {
+		seg = strings.Map(foldKana, seg)
+	}
+	if n&NormalizeLongVowel != 0 && (seg == "ー" || seg == "ｰ") && isKana(last) {
+		seg = ""
+	}
+	return seg
+}
+
+// foldKana returns the hiragana of a katakana r.
+func foldKana(r rune) rune {
+	switch {
+	case 'ァ' <= r && r <= 'ヶ', 'ヽ' <= r && r <= 'ヾ':
+		return r - ('ァ' - 'ぁ')
+	}
+	return r
+}
+
+func isKana(r rune) bool {
+	return unicode.In(r, unicode.Hiragana, unicode.Katakana) && r != 'ー' && r != 'ｰ'
+}
+
+// locate returns the ranges in the original text of all non-overlapping v in t.
+func (t *normalizedText) locate(v string) []suzuitoql.Range {
+	r := []suzuitoql.Range{}
+	if v == "" {
+		return r
+	}
+	for offset := 0; ; {
+		i := strings.Index(t.text[offset:], v)
+		if i < 0 {
+			break
+		}
+		start := offset + i
+		offset = start + len(v)
+		r = append(r, suzuitoql.Range{Start: t.starts[start], End: t.ends[offset-1]})
+	}
+	return r
+}
@@ -0,0 +1,84 @@
+package evalimpl
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/suzuito/suzuitoql"
+)
+
+func TestNormalize(t *testing.T) {
+	testCases := []struct {
+		input         string
+		normalization Normalization
+		expected      string
+	}{
+		{"ｺﾞｰｼｭ", NormalizeNFKC, "ゴーシュ"},
+		{"ＡＢＣ", NormalizeNFKC, "ABC"},
+		{"ＡＢＣ", NormalizeNFKC | NormalizeCase, "abc"},
+		{"ゴーシュ", NormalizeKana, "ごーしゅ"},
+		{"コンピューター", NormalizeLongVowel, "コンピュタ"},
+		{"ー", NormalizeLongVowel, "ー"},
+		{"パンダ", NormalizeDakuten, "ハンタ"},
+		{"ｺﾞｰｼｭ", NormalizeDakuten, "ｺｰｼｭ"},
+		{"ｺﾞｰｼｭ", NormalizeAll, "こしゅ"},
+		{"ゴーシュ", NormalizeAll, "こしゅ"},
+	}
+	for _, tC := range testCases {
+		if actual := Normalize(tC.input, tC.normalization); actual != tC.expected {
+			t.Errorf("Normalize(%q, %d): expected %q but %q", tC.input, tC.normalization, tC.expected, actual)
+		}
+	}
+}
+
+func TestEvaluatorNormalizedText(t *testing.T) {
+	e := NewEvaluatorNormalizedText(NormalizeAll)
+	e.Init("xｺﾞｰｼｭはＡＢＣを弾く")
+	testCases := []struct {
+		term     string
+		expected string
+	}{
+		{"ゴーシュ", "true [{1 16}]"},
+		{"ごしゅ", "true [{1 16}]"},
+		{"abc", "true [{19 28}]"},
+		{"ねずみ", "false []"},
+	}
+	for _, tC := range testCases {
+		r, err := e.EvalString(tC.term)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := fmt.Sprintf("%v %v", r, e.Locate(suzuitoql.StringValue(tC.term))); actual != tC.expected {
+			t.Errorf("%s: expected %s but %s", tC.term, tC.expected, actual)
+		}
+	}
+}
+
+func TestEvaluatorNormalizedTextZeroValue(t *testing.T) {
+	e := &EvaluatorNormalizedText{}
+	e.Init("セロ弾きのゴーシュ")
+	r, err := e.EvalString("ゴーシュ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := fmt.Sprintf("%v %v", r, e.Locate(suzuitoql.StringValue("ゴーシュ"))); actual != "true [{15 27}]" {
+		t.Errorf("expected true [{15 27}] but %s", actual)
+	}
+	if r, _ := e.EvalString("ごしゅ"); r {
+		t.Error("expected the zero value not to normalize terms")
+	}
+}
+
+func TestEvaluatorNormalizedTextLocateRegexp(t *testing.T) {
+	e := NewEvaluatorNormalizedText(NormalizeAll)
+	e.Init("ＡＢゴーシュとｺﾞｰｼｭ")
+	// Ranges are in the text before normalization, which Match matches
+	re := regexp.MustCompile(`ゴーシュ|ｺﾞｰｼｭ`)
+	if actual := fmt.Sprintf("%v", e.LocateRegexp(re)); actual != "[{6 18} {21 36}]" {
+		t.Errorf("expected [{6 18} {21 36}] but %s", actual)
+	}
+	if actual := fmt.Sprintf("%v", e.LocateRegexp(regexp.MustCompile(`x*`))); actual != "[]" {
+		t.Errorf("expected no empty ranges but %s", actual)
+	}
+}
@@ -0,0 +1,57 @@
+package evalimpl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// EvaluatorRecord evaluates records whose fields are texts, such as a title and a body.
+// Field terms such as `title:"ゴーシュ"` are searched in the field, and terms without fields in all the fields.
+type EvaluatorRecord struct {
+	fields map[string]string
+}
+
+func (e *EvaluatorRecord) Init(fields map[string]string) {
+	e.fields = fields
+}
+
+func (e *EvaluatorRecord) EvalFloat(v float64) (result bool, err error) {
+	return e.EvalString(fmt.Sprintf("%f", v))
+}
+
+func (e *EvaluatorRecord) EvalInt(v int64) (result bool, err error) {
+	return e.EvalString(fmt.Sprintf("%d", v))
+}
+
+func (e *EvaluatorRecord) EvalString(v string) (result bool, err error) {
+	for _, text := range e.fields {
+		if strings.Contains(text, v) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (e *EvaluatorRecord) EvalFieldFloat(field string, v float64) (result bool, err error) {
+	return e.EvalFieldString(field, fmt.Sprintf("%f", v))
+}
+
+func (e *EvaluatorRecord) EvalFieldInt(field string, v int64) (result bool, err error) {
+	return e.EvalFieldString(field, fmt.Sprintf("%d", v))
+}
+
+// EvalFieldString returns false if the record does not have the field.
+func (e *EvaluatorRecord) EvalFieldString(field string, v string) (result bool, err error) {
+	text, exists := e.fields[field]
+	return exists && strings.Contains(text, v), nil
+}
+
+func (e *EvaluatorRecord) EvalRegexp(re *regexp.Regexp) (result bool, err error) {
+	for _, text := range e.fields {
+		if re.MatchString(text) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
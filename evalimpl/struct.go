@@ -0,0 +1,357 @@
+package evalimpl
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/suzuito/suzuitoql"
+	"golang.org/x/xerrors"
+)
+
+// EvaluatorStruct evaluates Go values of structs. Queries refer to fields of the struct by paths
+// such as `Author.Name == "suzuito" && Tags contains "go"`.
+//
+// Names of fields are their Go names, or names given by tags such as `suzuitoql:"name"`.
+// Fields tagged with `suzuitoql:"-"` and unexported fields are hidden.
+// A path walks into nested structs, pointers, interfaces and maps with string keys, whose keys are names.
+// A path through slices and arrays refers to the field of each element.
+//
+// Terms without fields, Match and field terms search texts of string fields.
+type EvaluatorStruct struct {
+	value reflect.Value
+}
+
+func (e *EvaluatorStruct) Init(v interface{}) {
+	e.value = reflect.ValueOf(v)
+}
+
+func (e *EvaluatorStruct) EvalFloat(v float64) (result bool, err error) {
+	return e.EvalString(fmt.Sprintf("%f", v))
+}
+
+func (e *EvaluatorStruct) EvalInt(v int64) (result bool, err error) {
+	return e.EvalString(fmt.Sprintf("%d", v))
+}
+
+// EvalString returns true if any string field contains v.
+func (e *EvaluatorStruct) EvalString(v string) (result bool, err error) {
+	return walkStrings(e.value, map[uintptr]bool{}, func(s string) bool {
+		return strings.Contains(s, v)
+	}), nil
+}
+
+// EvalRegexp returns true if any string field matches re.
+func (e *EvaluatorStruct) EvalRegexp(re *regexp.Regexp) (result bool, err error) {
+	return walkStrings(e.value, map[uintptr]bool{}, re.MatchString), nil
+}
+
+func (e *EvaluatorStruct) EvalFieldFloat(field string, v float64) (result bool, err error) {
+	return e.evalField(field, suzuitoql.FloatValue(v))
+}
+
+func (e *EvaluatorStruct) EvalFieldInt(field string, v int64) (result bool, err error) {
+	return e.evalField(field, suzuitoql.IntValue(v))
+}
+
+func (e *EvaluatorStruct) EvalFieldString(field string, v string) (result bool, err error) {
+	return e.evalField(field, suzuitoql.StringValue(v))
+}
+
+// evalField evaluates the field term `field:v`, which is true if the field contains v or is equal to v.
+// Strings, slices, arrays and maps contain v as FieldContains does. The other fields such as numbers are compared with v.
+func (e *EvaluatorStruct) evalField(field string, v suzuitoql.Value) (bool, error) {
+	result := false
+	var err error
+	if lerr := lookupPath(e.value, strings.Split(field, "."), func(f reflect.Value) {
+		if result || err != nil {
+			return
+		}
+		switch f.Kind() {
+		case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+			result, err = containsValue(f, v)
+			return
+		}
+		value, ok := valueOf(f)
+		if !ok {
+			err = xerrors.Errorf("Field of %s is not a value", f.Type())
+			return
+		}
+		result = equalValues(value, v)
+	}); lerr != nil {
+		return false, lerr
+	}
+	return result, err
+}
+
+// FieldValue returns the value of the field at path, which must be a single string, number or bool.
+// It returns suzuitoql.ErrNoValue if the field is missing in maps or nil.
+func (e *EvaluatorStruct) FieldValue(path []string) (suzuitoql.Value, error) {
+	var values []reflect.Value
+	if err := lookupPath(e.value, path, func(v reflect.Value) {
+		values = append(values, v)
+	}); err != nil {
+		return suzuitoql.Value{}, err
+	}
+	if len(values) == 0 {
+		return suzuitoql.Value{}, suzuitoql.ErrNoValue
+	}
+	if len(values) != 1 {
+		return suzuitoql.Value{}, xerrors.Errorf("Field has %d values", len(values))
+	}
+	v, ok := valueOf(values[0])
+	if !ok {
+		return suzuitoql.Value{}, xerrors.Errorf("Field of %s is not a value", values[0].Type())
+	}
+	return v, nil
+}
+
+// FieldContains returns true if the field at path contains v.
+// A slice or an array contains its elements, a map contains its keys, and a string contains its substrings.
+func (e *EvaluatorStruct) FieldContains(path []string, v suzuitoql.Value) (bool, error) {
+	result := false
+	var err error
+	if lerr := lookupPath(e.value, path, func(field reflect.Value) {
+		if result || err != nil {
+			return
+		}
+		result, err = containsValue(field, v)
+	}); lerr != nil {
+		return false, lerr
+	}
+	return result, err
+}
+
+func containsValue(field reflect.Value, v suzuitoql.Value) (bool, error) {
+	switch field.Kind() {
+	case reflect.String:
+		return strings.Contains(field.String(), textOf(v)), nil
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < field.Len(); i++ {
+			if elem, ok := valueOf(indirect(field.Index(i))); ok && equalValues(elem, v) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case reflect.Map:
+		iter := field.MapRange()
+		for iter.Next() {
+			if key, ok := valueOf(iter.Key()); ok && equalValues(key, v) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, xerrors.Errorf("Field of %s cannot contain values", field.Type())
+}
+
+// lookupPath calls found with each value at path in v, with pointers and interfaces dereferenced.
+// Nil pointers and missing keys of maps have no values.
+func lookupPath(v reflect.Value, path []string, found func(v reflect.Value)) error {
+	v = indirect(v)
+	if !v.IsValid() {
+		return nil
+	}
+	if len(path) == 0 {
+		found(v)
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		field, exists := structTypeOf(v.Type()).fields[path[0]]
+		if !exists {
+			return xerrors.Errorf("%s has no field '%s'", v.Type(), path[0])
+		}
+		return lookupPath(v.Field(field.index), path[1:], found)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return xerrors.Errorf("Keys of %s are not string", v.Type())
+		}
+		elem := v.MapIndex(reflect.ValueOf(path[0]).Convert(v.Type().Key()))
+		return lookupPath(elem, path[1:], found)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := lookupPath(v.Index(i), path, found); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return xerrors.Errorf("%s has no field '%s'", v.Type(), path[0])
+}
+
+// walkStrings returns true if f returns true for any string in v.
+// visited holds pointers being walked, to stop at cycles.
+func walkStrings(v reflect.Value, visited map[uintptr]bool, f func(s string) bool) bool {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || visited[v.Pointer()] {
+			return false
+		}
+		visited[v.Pointer()] = true
+		defer delete(visited, v.Pointer())
+		return walkStrings(v.Elem(), visited, f)
+	case reflect.Interface:
+		return !v.IsNil() && walkStrings(v.Elem(), visited, f)
+	case reflect.String:
+		return f(v.String())
+	case reflect.Struct:
+		for _, field := range structTypeOf(v.Type()).list {
+			if walkStrings(v.Field(field.index), visited, f) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if walkStrings(v.Index(i), visited, f) {
+				return true
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if walkStrings(iter.Value(), visited, f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// valueOf returns v as a value of suzuitoql. Unsigned integers larger than int64 are float.
+func valueOf(v reflect.Value) (suzuitoql.Value, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return suzuitoql.StringValue(v.String()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return suzuitoql.IntValue(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return suzuitoql.FloatValue(float64(v.Uint())), true
+		}
+		return suzuitoql.IntValue(int64(v.Uint())), true
+	case reflect.Float32, reflect.Float64:
+		return suzuitoql.FloatValue(v.Float()), true
+	case reflect.Bool:
+		return suzuitoql.BoolValue(v.Bool()), true
+	}
+	return suzuitoql.Value{}, false
+}
+
+// equalValues returns true if a == b in queries.
+func equalValues(a, b suzuitoql.Value) bool {
+	switch {
+	case a.Type == suzuitoql.TypeInt && b.Type == suzuitoql.TypeInt:
+		return a.Int == b.Int
+	case isNumber(a) && isNumber(b):
+		return toFloat(a) == toFloat(b)
+	}
+	return a == b
+}
+
+func isNumber(v suzuitoql.Value) bool {
+	return v.Type == suzuitoql.TypeInt || v.Type == suzuitoql.TypeFloat
+}
+
+func toFloat(v suzuitoql.Value) float64 {
+	if v.Type == suzuitoql.TypeInt {
+		return float64(v.Int)
+	}
+	return v.Float
+}
+
+// textOf returns v as a text searched in strings, in the same way as EvaluatorText.
+func textOf(v suzuitoql.Value) string {
+	switch v.Type {
+	case suzuitoql.TypeInt:
+		return fmt.Sprintf("%d", v.Int)
+	case suzuitoql.TypeFloat:
+		return fmt.Sprintf("%f", v.Float)
+	case suzuitoql.TypeBool:
+		return fmt.Sprintf("%v", v.Bool)
+	}
+	return v.Str
+}
+
+// structType is the reflection metadata of a struct type, which is cached in structTypes.
+type structType struct {
+	fields map[string]structField
+	// list is fields in the order of declaration
+	list []structField
+}
+
+type structField struct {
+	name  string
+	index int
+}
+
+var structTypes sync.Map // reflect.Type -> *structType
+
+func structTypeOf(t reflect.Type) *structType {
+	if st, exists := structTypes.Load(t); exists {
+		return st.(*structType)
+	}
+	st := &structType{
+		fields: map[string]structField{},
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag, exists := f.Tag.Lookup("suzuitoql"); exists {
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		if _, exists := st.fields[name]; exists {
+			continue
+		}
+		field := structField{name: name, index: i}
+		st.fields[name] = field
+		st.list = append(st.list, field)
+	}
+	actual, _ := structTypes.LoadOrStore(t, st)
+	return actual.(*structType)
+}
+
+// StructFieldPaths returns paths of fields of the struct v, which can be used as suzuitoql.Schema.Fields.
+// Paths into maps are not included, because their keys are unknown.
+func StructFieldPaths(v interface{}) []string {
+	paths := []string{}
+	appendFieldPaths(&paths, reflect.TypeOf(v), "", map[reflect.Type]bool{})
+	return paths
+}
+
+func appendFieldPaths(paths *[]string, t reflect.Type, prefix string, visiting map[reflect.Type]bool) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || visiting[t] {
+		return
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+	for _, field := range structTypeOf(t).list {
+		path := prefix + field.name
+		*paths = append(*paths, path)
+		appendFieldPaths(paths, t.Field(field.index).Type, path+".", visiting)
+	}
+}
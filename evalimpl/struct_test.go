@@ -0,0 +1,123 @@
+package evalimpl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/suzuito/suzuitoql"
+)
+
+type testAuthor struct {
+	Name string `suzuitoql:"name"`
+	Age  uint8
+}
+
+type testBook struct {
+	Title    string
+	Author   *testAuthor
+	Authors  []testAuthor
+	Tags     []string
+	Meta     map[string]interface{}
+	Year     int
+	Price    float64
+	Sold     bool
+	Related  *testBook
+	Internal string `suzuitoql:"-"`
+	note     string
+}
+
+func TestEvaluatorStruct(t *testing.T) {
+	book := &testBook{
+		Title:    "セロ弾きのゴーシュ",
+		Author:   &testAuthor{Name: "宮沢賢治", Age: 37},
+		Authors:  []testAuthor{{Name: "宮沢賢治"}, {Name: "suzuito"}},
+		Tags:     []string{"童話", "go"},
+		Meta:     map[string]interface{}{"lang": "ja", "pages": 32},
+		Year:     1934,
+		Price:    5.5,
+		Internal: "秘密",
+		note:     "秘密",
+	}
+	book.Related = book
+	testCases := []struct {
+		query       string
+		expected    bool
+		expectedErr string
+	}{
+		{query: `Author.name == "宮沢賢治" && Tags contains "go"`, expected: true},
+		{query: `Author.Age >= 37 && Year < 2000 && Price == 5.5 && !Sold`, expected: true},
+		{query: `Authors.name contains "suzu" && !(Authors.name contains "ねずみ")`, expected: true},
+		{query: `Title contains "ゴーシュ" && Author.name:"賢治" && Tags:"童話"`, expected: true},
+		{query: `Related.Related.Title == Title && "ゴーシュ" && !"秘密"`, expected: true},
+		{query: `Meta.lang == "ja" && Meta.pages * 2 == 64 && Meta contains "lang"`, expected: true},
+		{query: `Year:1934 && Price:5.5 && Author.Age:37 && Meta.pages:32 && !Year:1935`, expected: true},
+		{query: `Meta.author == "suzuito" || !(Meta.author != "suzuito")`, expected: true},
+		{query: `Match("^セロ") && Year contains 1934`, expectedErr: "1:17: cannot evaluate field 'Year': Field of int cannot contain values"},
+		{query: `Authors.name == "suzuito"`, expectedErr: "1:1: cannot evaluate field 'Authors.name': Field has 2 values"},
+		{query: `Internal == "秘密"`, expectedErr: "1:1: cannot evaluate field 'Internal': evalimpl.testBook has no field 'Internal'"},
+	}
+	e := &EvaluatorStruct{}
+	e.Init(book)
+	for _, tC := range testCases {
+		t.Run(tC.query, func(t *testing.T) {
+			f, err := suzuitoql.GenerateFilterFromString(tC.query)
+			if err == nil {
+				var r bool
+				r, err = f.Eval(e)
+				if err == nil && r != tC.expected {
+					t.Errorf("expected %v but %v", tC.expected, r)
+				}
+			}
+			actualErr := ""
+			if err != nil {
+				actualErr = strings.TrimPrefix(err.Error(), ": ")
+			}
+			if actualErr != tC.expectedErr {
+				t.Errorf("expected error %q but %q", tC.expectedErr, actualErr)
+			}
+		})
+	}
+}
+
+func TestEvaluatorStructWithNil(t *testing.T) {
+	testCases := []struct {
+		query    string
+		expected bool
+	}{
+		{query: `Author.name == "宮沢賢治"`, expected: false},
+		{query: `!(Author.Age > 30) && Title == "ねずみ"`, expected: true},
+		{query: `Author.Age + 1 > 0 || Year:0`, expected: true},
+		{query: `Author.name contains "賢治" || Related.Title:"ねずみ" || Tags contains "go"`, expected: false},
+		{query: `Author.name:"賢治" || Meta.lang:"ja"`, expected: false},
+	}
+	e := &EvaluatorStruct{}
+	e.Init(&testBook{Title: "ねずみ"})
+	for _, tC := range testCases {
+		t.Run(tC.query, func(t *testing.T) {
+			f, err := suzuitoql.GenerateFilterFromString(tC.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := f.Eval(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r != tC.expected {
+				t.Errorf("expected %v but %v", tC.expected, r)
+			}
+		})
+	}
+}
+
+func TestStructFieldPaths(t *testing.T) {
+	expected := "Title,Author,Author.name,Author.Age,Authors,Authors.name,Authors.Age,Tags,Meta,Year,Price,Sold,Related"
+	paths := StructFieldPaths(&testBook{})
+	if actual := strings.Join(paths, ","); actual != expected {
+		t.Errorf("expected %s but %s", expected, actual)
+	}
+	_, err := suzuitoql.Compile(`Author.name == "suzuito" || Internal == "秘密" || note == "秘密"`, &suzuitoql.Schema{Fields: paths})
+	expectedErr := "1:29: unknown field 'Internal' (and 1 more errors)"
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("expected error %q but %v", expectedErr, err)
+	}
+}
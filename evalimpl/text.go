@@ -2,7 +2,10 @@ package evalimpl
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
+
+	"github.com/suzuito/suzuitoql"
 )
 
 type EvaluatorText struct {
@@ -25,6 +28,64 @@ func (e *EvaluatorText) EvalString(v string) (result bool, err error) {
 	return strings.Contains(e.text, v), nil
 }
 
+// Locate returns all non-overlapping ranges of v in the text.
+func (e *EvaluatorText) Locate(v suzuitoql.Value) []suzuitoql.Range {
+	var term string
+	switch v.Type {
+	case suzuitoql.TypeString:
+		term = v.Str
+	case suzuitoql.TypeInt:
+		term = fmt.Sprintf("%d", v.Int)
+	case suzuitoql.TypeFloat:
+		term = fmt.Sprintf("%f", v.Float)
+	default:
+		return nil
+	}
+	if term == "" {
+		return nil
+	}
+	r := []suzuitoql.Range{}
+	for offset := 0; ; {
+		i := strings.Index(e.text[offset:], term)
+		if i < 0 {
+			break
+		}
+		start := offset + i
+		offset = start + len(term)
+		r = append(r, suzuitoql.Range{Start: start, End: offset})
+	}
+	return r
+}
+
+func (e *EvaluatorText) EvalRegexp(re *regexp.Regexp) (result bool, err error) {
+	return re.MatchString(e.text), nil
+}
+
+// LocateRegexp returns all non-overlapping ranges matching re in the text.
+func (e *EvaluatorText) LocateRegexp(re *regexp.Regexp) []suzuitoql.Range {
+	return locateRegexp(e.text, re)
+}
+
+// locateRegexp returns ranges of non-empty matches of re in text.
+func locateRegexp(text string, re *regexp.Regexp) []suzuitoql.Range {
+	r := []suzuitoql.Range{}
+	for _, m := range re.FindAllStringIndex(text, -1) {
+		if m[0] < m[1] {
+			r = append(r, suzuitoql.Range{Start: m[0], End: m[1]})
+		}
+	}
+	return r
+}
+
 func (e *EvaluatorText) Not(v string) (result bool, err error) {
 	return !strings.Contains(e.text, v), nil
 }
+
+// NewTextFunctions returns functions callable from queries evaluated by EvaluatorText.
+func NewTextFunctions() *suzuitoql.FunctionRegistry {
+	r := suzuitoql.NewFunctionRegistry()
+	if err := r.RegisterMethods(&EvaluatorText{}, "Not"); err != nil {
+		panic(err)
+	}
+	return r
+}
@@ -0,0 +1,129 @@
+package evalimpl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/suzuito/suzuitoql"
+)
+
+// EvaluatorAhoCorasick is EvaluatorText which scans a text only once for all terms of a filter.
+// Init finds all the terms in the text by an Aho-Corasick automaton built from the filter,
+// and EvalString answers from the found terms.
+// Terms not in the filter, such as results of functions, are searched in the same way as EvaluatorText.
+// The zero value has no automaton, so it searches every term in the same way as EvaluatorText.
+type EvaluatorAhoCorasick struct {
+	automaton *ahoCorasick
+	// ids are IDs of the patterns of the automaton
+	ids  map[string]int32
+	text string
+	// found[id] == generation if the pattern id is found in the current text
+	found      []uint32
+	generation uint32
+}
+
+// NewEvaluatorAhoCorasick returns an evaluator for filter.
+// It builds the automaton from string, int and float literals in filter.
+func NewEvaluatorAhoCorasick(filter *suzuitoql.Filter) *EvaluatorAhoCorasick {
+	ids := map[string]int32{}
+	patterns := []string{}
+	suzuitoql.Inspect(filter.Expr(), func(node suzuitoql.Expr) bool {
+		literal, ok := node.(*suzuitoql.Literal)
+		if !ok {
+			return true
+		}
+		var pattern string
+		switch literal.Value.Type {
+		case suzuitoql.TypeString:
+			pattern = literal.Value.Str
+		case suzuitoql.TypeInt:
+			pattern = fmt.Sprintf("%d", literal.Value.Int)
+		case suzuitoql.TypeFloat:
+			pattern = fmt.Sprintf("%f", literal.Value.Float)
+		default:
+			return true
+		}
+		if _, exists := ids[pattern]; !exists && pattern != "" {
+			ids[pattern] = int32(len(patterns))
+			patterns = append(patterns, pattern)
+		}
+		return true
+	})
+	return &EvaluatorAhoCorasick{
+		automaton: newAhoCorasick(patterns),
+		ids:       ids,
+		found:     make([]uint32, len(patterns)),
+	}
+}
+
+// Clone returns an evaluator sharing the automaton with e, which can be used in another goroutine.
+func (e *EvaluatorAhoCorasick) Clone() *EvaluatorAhoCorasick {
+	return &EvaluatorAhoCorasick{
+		automaton: e.automaton,
+		ids:       e.ids,
+		found:     make([]uint32, len(e.found)),
+	}
+}
+
+func (e *EvaluatorAhoCorasick) Init(s string) {
+	e.text = s
+	if e.automaton == nil {
+		return
+	}
+	e.generation++
+	if e.generation == 0 {
+		// Clear found, whose values may equal generation after the overflow
+		for i := range e.found {
+			e.found[i] = 0
+		}
+		e.generation = 1
+	}
+	e.automaton.scan(s, func(id int32) {
+		e.found[id] = e.generation
+	})
+}
+
+func (e *EvaluatorAhoCorasick) EvalFloat(v float64) (result bool, err error) {
+	return e.EvalString(fmt.Sprintf("%f", v))
+}
+
+func (e *EvaluatorAhoCorasick) EvalInt(v int64) (result bool, err error) {
+	return e.EvalString(fmt.Sprintf("%d", v))
+}
+
+func (e *EvaluatorAhoCorasick) EvalString(v string) (result bool, err error) {
+	if id, exists := e.ids[v]; exists {
+		return e.found[id] == e.generation, nil
+	}
+	return strings.Contains(e.text, v), nil
+}
+
+func (e *EvaluatorAhoCorasick) EvalRegexp(re *regexp.Regexp) (result bool, err error) {
+	return re.MatchString(e.text), nil
+}
+
+func (e *EvaluatorAhoCorasick) Not(v string) (result bool, err error) {
+	r, err := e.EvalString(v)
+	return !r, err
+}
+
+// Locate returns all non-overlapping ranges of v in the text.
+func (e *EvaluatorAhoCorasick) Locate(v suzuitoql.Value) []suzuitoql.Range {
+	t := EvaluatorText{text: e.text}
+	return t.Locate(v)
+}
+
+// LocateRegexp returns all non-overlapping ranges matching re in the text.
+func (e *EvaluatorAhoCorasick) LocateRegexp(re *regexp.Regexp) []suzuitoql.Range {
+	return locateRegexp(e.text, re)
+}
+
+// NewAhoCorasickFunctions returns functions callable from queries evaluated by EvaluatorAhoCorasick.
+func NewAhoCorasickFunctions() *suzuitoql.FunctionRegistry {
+	r := suzuitoql.NewFunctionRegistry()
+	if err := r.RegisterMethods(&EvaluatorAhoCorasick{}, "Not"); err != nil {
+		panic(err)
+	}
+	return r
+}
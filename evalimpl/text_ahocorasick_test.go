@@ -0,0 +1,113 @@
+package evalimpl
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/suzuito/suzuitoql"
+)
+
+func TestEvaluatorAhoCorasick(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	randomString := func(n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = "abc"[rnd.Intn(3)]
+		}
+		return string(b)
+	}
+	terms := []string{`"ゴーシュ"`, `"シュ"`, `"ねずみ"`, `""`, "1", "1.5"}
+	for i := 0; i < 100; i++ {
+		terms = append(terms, fmt.Sprintf("%q", randomString(1+rnd.Intn(5))))
+	}
+	filter, err := suzuitoql.GenerateFilterFromString(strings.Join(terms, " || "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEvaluatorAhoCorasick(filter).Clone()
+	expected := EvaluatorText{}
+	texts := []string{"", "ゴーシュ", "ゴーシ", "1.500000", "x1y"}
+	for i := 0; i < 100; i++ {
+		texts = append(texts, randomString(rnd.Intn(20)))
+	}
+	for _, text := range texts {
+		e.Init(text)
+		expected.Init(text)
+		for _, term := range append(terms, `"not in the filter"`, `"b"`) {
+			v, err := suzuitoql.ParseExpr(term)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var actualResult, expectedResult bool
+			switch l := v.(*suzuitoql.Literal).Value; l.Type {
+			case suzuitoql.TypeString:
+				actualResult, _ = e.EvalString(l.Str)
+				expectedResult, _ = expected.EvalString(l.Str)
+			case suzuitoql.TypeInt:
+				actualResult, _ = e.EvalInt(l.Int)
+				expectedResult, _ = expected.EvalInt(l.Int)
+			case suzuitoql.TypeFloat:
+				actualResult, _ = e.EvalFloat(l.Float)
+				expectedResult, _ = expected.EvalFloat(l.Float)
+			}
+			if actualResult != expectedResult {
+				t.Errorf("%s in %q: expected %v but %v", term, text, expectedResult, actualResult)
+			}
+		}
+	}
+}
+
+func TestEvaluatorAhoCorasickZeroValue(t *testing.T) {
+	for _, e := range []*EvaluatorAhoCorasick{{}, (&EvaluatorAhoCorasick{}).Clone()} {
+		e.Init("セロ弾きのゴーシュ")
+		if r, err := e.EvalString("ゴーシュ"); err != nil || !r {
+			t.Errorf("expected true but (%v, %v)", r, err)
+		}
+		if r, err := e.EvalString("ねずみ"); err != nil || r {
+			t.Errorf("expected false but (%v, %v)", r, err)
+		}
+		if actual := fmt.Sprintf("%v", e.Locate(suzuitoql.StringValue("ゴーシュ"))); actual != "[{15 27}]" {
+			t.Errorf("expected [{15 27}] but %s", actual)
+		}
+	}
+}
+
+func benchmarkTextEvaluator(b *testing.B, newEvaluator func(filter *suzuitoql.Filter) textEvaluator) {
+	terms := make([]string, 1000)
+	for i := range terms {
+		terms[i] = fmt.Sprintf(`"ゴーシュ%d"`, i)
+	}
+	filter, err := suzuitoql.GenerateFilterFromString(strings.Join(terms, " || "))
+	if err != nil {
+		b.Fatal(err)
+	}
+	e := newEvaluator(filter)
+	text := strings.Repeat("ゴーシュは町の活動写真館でセロを弾く係りでした。", 4)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.Init(text)
+		if _, err := filter.Eval(e); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type textEvaluator interface {
+	suzuitoql.Evaluator
+	Init(s string)
+}
+
+func BenchmarkEvaluatorText(b *testing.B) {
+	benchmarkTextEvaluator(b, func(filter *suzuitoql.Filter) textEvaluator {
+		return &EvaluatorText{}
+	})
+}
+
+func BenchmarkEvaluatorAhoCorasick(b *testing.B) {
+	benchmarkTextEvaluator(b, func(filter *suzuitoql.Filter) textEvaluator {
+		return NewEvaluatorAhoCorasick(filter)
+	})
+}
@@ -0,0 +1,92 @@
+package evalimpl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/suzuito/suzuitoql"
+)
+
+// EvaluatorNormalizedText is EvaluatorText which normalizes both the text and terms before matching.
+// The zero value normalizes nothing.
+type EvaluatorNormalizedText struct {
+	normalization Normalization
+	original      string
+	text          normalizedText
+	// terms caches normalized terms, because the same terms are evaluated for every text.
+	// It is made by the first normalization.
+	terms map[string]string
+}
+
+func NewEvaluatorNormalizedText(n Normalization) *EvaluatorNormalizedText {
+	return &EvaluatorNormalizedText{
+		normalization: n,
+	}
+}
+
+func (e *EvaluatorNormalizedText) Init(s string) {
+	e.original = s
+	e.text = newNormalizedText(s, e.normalization)
+}
+
+func (e *EvaluatorNormalizedText) EvalFloat(v float64) (result bool, err error) {
+	return e.EvalString(fmt.Sprintf("%f", v))
+}
+
+func (e *EvaluatorNormalizedText) EvalInt(v int64) (result bool, err error) {
+	return e.EvalString(fmt.Sprintf("%d", v))
+}
+
+func (e *EvaluatorNormalizedText) EvalString(v string) (result bool, err error) {
+	return strings.Contains(e.text.text, e.normalize(v)), nil
+}
+
+// EvalRegexp matches re with the text before normalization, because patterns cannot be normalized.
+func (e *EvaluatorNormalizedText) EvalRegexp(re *regexp.Regexp) (result bool, err error) {
+	return re.MatchString(e.original), nil
+}
+
+func (e *EvaluatorNormalizedText) Not(v string) (result bool, err error) {
+	r, err := e.EvalString(v)
+	return !r, err
+}
+
+// Locate returns all non-overlapping ranges of v in the text before normalization.
+func (e *EvaluatorNormalizedText) Locate(v suzuitoql.Value) []suzuitoql.Range {
+	switch v.Type {
+	case suzuitoql.TypeString:
+		return e.text.locate(e.normalize(v.Str))
+	case suzuitoql.TypeInt:
+		return e.text.locate(e.normalize(fmt.Sprintf("%d", v.Int)))
+	case suzuitoql.TypeFloat:
+		return e.text.locate(e.normalize(fmt.Sprintf("%f", v.Float)))
+	}
+	return nil
+}
+
+// LocateRegexp returns all non-overlapping ranges matching re in the text before normalization.
+func (e *EvaluatorNormalizedText) LocateRegexp(re *regexp.Regexp) []suzuitoql.Range {
+	return locateRegexp(e.original, re)
+}
+
+func (e *EvaluatorNormalizedText) normalize(v string) string {
+	if n, exists := e.terms[v]; exists {
+		return n
+	}
+	n := Normalize(v, e.normalization)
+	if e.terms == nil {
+		e.terms = map[string]string{}
+	}
+	e.terms[v] = n
+	return n
+}
+
+// NewNormalizedTextFunctions returns functions callable from queries evaluated by EvaluatorNormalizedText.
+func NewNormalizedTextFunctions() *suzuitoql.FunctionRegistry {
+	r := suzuitoql.NewFunctionRegistry()
+	if err := r.RegisterMethods(&EvaluatorNormalizedText{}, "Not"); err != nil {
+		panic(err)
+	}
+	return r
+}
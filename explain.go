@@ -0,0 +1,162 @@
+package suzuitoql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Explanation is a node of the tree returned by Filter.Explain, which mirrors the syntax tree of the filter.
+type Explanation struct {
+	Node Expr
+	// Evaluated is false if the node was skipped by short-circuit evaluation or an error.
+	Evaluated bool
+	// Value is the value of the node, such as the result of a function call.
+	Value Value
+	// Term is true if the evaluator evaluated Value into Result.
+	Term bool
+	// Result is the node evaluated as bool if Value is bool or Term is true.
+	Result bool
+	// Err is the error which occurred at the node, not at its children.
+	Err error
+	// Duration is the time taken to evaluate the node including its children.
+	Duration time.Duration
+	// Children are explanations of operands and arguments of the node, in the order in the query.
+	Children []*Explanation
+}
+
+// Explain evaluates the filter as Eval does, recording the value of every node.
+// It is much slower than Eval, so use it only for debugging queries.
+// If the evaluation fails, the error is returned with the explanation recorded until the error.
+func (f *Filter) Explain(evaluator Evaluator) (*Explanation, error) {
+	return f.ExplainContext(context.Background(), evaluator)
+}
+
+// ExplainContext is Explain which stops when ctx is done. See EvalContext.
+func (f *Filter) ExplainContext(ctx context.Context, evaluator Evaluator) (*Explanation, error) {
+	f.tracedOnce.Do(func() {
+		f.traced = newProgram(f.root, f.opts, f.regexps, true)
+	})
+	root := newExplanation(f.root)
+	t := &tracer{explanations: map[Expr]*Explanation{}}
+	t.add(root)
+	_, err := f.evalWith(ctx, evaluator, func(ctx context.Context, evaluator Evaluator) (bool, error) {
+		result, _, err := f.traced.run(ctx, evaluator, false, t)
+		return result, err
+	})
+	return root, err
+}
+
+func newExplanation(node Expr) *Explanation {
+	e := &Explanation{Node: node}
+	for _, child := range children(node) {
+		e.Children = append(e.Children, newExplanation(child))
+	}
+	return e
+}
+
+// tracer records the evaluation of a program compiled for tracing into explanations.
+type tracer struct {
+	explanations map[Expr]*Explanation
+	// failed is true after the first error, which is recorded at the node where it occurred
+	failed bool
+}
+
+func (t *tracer) add(e *Explanation) {
+	t.explanations[e.Node] = e
+	for _, child := range e.Children {
+		t.add(child)
+	}
+}
+
+// enter is called before node is evaluated.
+func (t *tracer) enter(node Expr) (*Explanation, time.Time) {
+	e := t.explanations[node]
+	e.Evaluated = true
+	return e, time.Now()
+}
+
+// leave is called after the node of e is evaluated.
+func (t *tracer) leave(e *Explanation, start time.Time, err error) {
+	e.Duration = time.Since(start)
+	if err == nil || t.failed {
+		return
+	}
+	if _, ok := err.(*falseError); ok {
+		// The evaluation goes on. See evaluatedAsFalse.
+		return
+	}
+	e.Err = err
+	t.failed = true
+}
+
+// evaluatedAsFalse records err at the node which has no value, which makes its parent evaluate to false.
+func (t *tracer) evaluatedAsFalse(err *falseError) {
+	t.explanations[err.node].Err = err
+}
+
+// term records that the evaluator evaluated v of node into result.
+func (t *tracer) term(node Expr, v Value, result bool) {
+	e := t.explanations[node]
+	e.Value, e.Term, e.Result = v, v.Type != TypeBool, result
+}
+
+// touch records v of node which is evaluated as a part of its parent, such as the term of a field term.
+func (t *tracer) touch(node Expr, v Value) {
+	e := t.explanations[node]
+	e.Evaluated = true
+	e.Value = v
+}
+
+// String returns the explanation as an indented tree, one node per line.
+func (e *Explanation) String() string {
+	b := strings.Builder{}
+	e.write(&b, 0)
+	return b.String()
+}
+
+func (e *Explanation) write(b *strings.Builder, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	switch n := e.Node.(type) {
+	case *BinaryOp:
+		b.WriteString(string(n.Op))
+	case *UnaryOp:
+		b.WriteString(string(n.Op))
+	case *Not:
+		b.WriteString(string(OpNot))
+	case *Call:
+		b.WriteString(n.Name + "()")
+	default:
+		b.WriteString(FormatExpr(n, FormatOptions{}))
+	}
+	literal := false
+	switch e.Node.(type) {
+	case *Literal, *FieldTerm:
+		literal = true
+	}
+	_, ref := e.Node.(*FieldRef)
+	switch {
+	case e.Err != nil:
+		fmt.Fprintf(b, " => error: %s", e.Err)
+	case !e.Evaluated:
+		b.WriteString(" => not evaluated")
+	case ref && e.Value.Type == "":
+		// The field of contains has no value
+	case e.Value.Type == "":
+		b.WriteString(" => aborted")
+	case e.Term && literal:
+		fmt.Fprintf(b, " => %v", e.Result)
+	case e.Term:
+		fmt.Fprintf(b, " => %s => %v", e.Value, e.Result)
+	case !literal:
+		fmt.Fprintf(b, " => %s", e.Value)
+	}
+	if e.Evaluated {
+		fmt.Fprintf(b, " (%s)", e.Duration)
+	}
+	b.WriteString("\n")
+	for _, child := range e.Children {
+		child.write(b, depth+1)
+	}
+}
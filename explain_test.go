@@ -0,0 +1,97 @@
+package suzuitoql
+
+import (
+	"context"
+	"regexp"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	testCases := []struct {
+		query    string
+		text     string
+		expected string
+	}{
+		{
+			query: `("a" && "c") || !"d" && Len("ab") + 1 == 3`,
+			text:  "ab",
+			expected: `|| => true
+  && => false
+    "a" => true
+    "c" => false
+  && => true
+    ! => true
+      "d" => false
+    == => true
+      + => 3
+        Len() => 2
+          "ab"
+        1
+      3
+`,
+		},
+		{
+			query: `"c" && HasPrefix(1) || HasPrefix("a")`,
+			text:  "ab",
+			expected: `|| => true
+  && => false
+    "c" => false
+    HasPrefix() => not evaluated
+      1 => not evaluated
+  HasPrefix() => true
+    "a"
+`,
+		},
+		{
+			query: `"a" && HasPrefix(1)`,
+			text:  "ab",
+			expected: `&& => aborted
+  "a" => true
+  HasPrefix() => error: 1:18: argument 1 of function 'HasPrefix' must be string but int given
+    1
+`,
+		},
+	}
+	durations := regexp.MustCompile(` \([^)]*\)\n`)
+	for _, tC := range testCases {
+		t.Run(tC.query, func(t *testing.T) {
+			f, err := GenerateFilterFromString(tC.query, WithFunctions(newTestFunctions(t)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			evaluator := &testEvaluator{text: tC.text}
+			e, err := f.Explain(evaluator)
+			result, evalErr := f.Eval(evaluator)
+			if errorMessage(err) != errorMessage(evalErr) || e.Result != result {
+				t.Errorf("expected (%v, %v) as Eval but (%v, %v)", result, evalErr, e.Result, err)
+			}
+			if actual := durations.ReplaceAllString(e.String(), "\n"); actual != tC.expected {
+				t.Errorf("expected\n%s\nbut\n%s", tC.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExplainContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	evaluator := &testRecordEvaluator{
+		fields: map[string]string{"title": "セロ弾きのゴーシュ"},
+	}
+	for _, query := range []string{`title:"ゴーシュ"`, `title contains "ゴーシュ"`, `"セロ" || title:"ゴーシュ"`} {
+		t.Run(query, func(t *testing.T) {
+			f, err := GenerateFilterFromString(query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, evalErr := f.EvalContext(ctx, evaluator)
+			e, err := f.ExplainContext(ctx, evaluator)
+			if evalErr == nil || errorMessage(err) != errorMessage(evalErr) {
+				t.Errorf("expected %v as EvalContext but %v", evalErr, err)
+			}
+			if e.Err == nil && (len(e.Children) == 0 || e.Children[0].Err == nil) {
+				t.Errorf("expected the error recorded at the node but\n%s", e)
+			}
+		})
+	}
+}
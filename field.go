@@ -0,0 +1,157 @@
+package suzuitoql
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// FieldEvaluator is implemented by evaluators of records with fields, such as a title and a body.
+// It evaluates field terms such as `title:"ゴーシュ"`, while Evaluator evaluates terms without fields.
+type FieldEvaluator interface {
+	EvalFieldFloat(field string, v float64) (result bool, err error)
+	EvalFieldInt(field string, v int64) (result bool, err error)
+	EvalFieldString(field string, v string) (result bool, err error)
+}
+
+// UnknownFieldError is returned when a query refers to a field the schema does not declare.
+type UnknownFieldError struct {
+	Loc  Span
+	Name string
+}
+
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("%s: unknown field '%s'", e.Loc.Start, e.Name)
+}
+
+func (e *UnknownFieldError) Span() Span { return e.Loc }
+
+// FieldError is returned when the evaluator cannot evaluate a field term.
+type FieldError struct {
+	Loc   Span
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: cannot evaluate field '%s': %s", e.Loc.Start, e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error { return e.Err }
+
+func (e *FieldError) Span() Span { return e.Loc }
+
+// evalFieldTerm evaluates n by the evaluator, which must be a FieldEvaluator.
+func evalFieldTerm(evaluator Evaluator, n *FieldTerm) (bool, error) {
+	e, ok := evaluator.(FieldEvaluator)
+	if !ok {
+		return false, &FieldError{
+			Loc:   n.Loc,
+			Field: n.Field,
+			Err:   xerrors.Errorf("Evaluator %T does not implement FieldEvaluator", evaluator),
+		}
+	}
+	var result bool
+	var err error
+	switch v := n.Term.Value; v.Type {
+	case TypeFloat:
+		result, err = e.EvalFieldFloat(n.Field, v.Float)
+	case TypeInt:
+		result, err = e.EvalFieldInt(n.Field, v.Int)
+	case TypeString:
+		result, err = e.EvalFieldString(n.Field, v.Str)
+	default:
+		return false, &OperandTypeError{
+			Loc:    n.Term.Loc,
+			Op:     ":",
+			Actual: string(v.Type),
+		}
+	}
+	if err != nil {
+		return false, &FieldError{
+			Loc:   n.Loc,
+			Field: n.Field,
+			Err:   err,
+		}
+	}
+	return result, nil
+}
+
+// ErrNoValue is returned by RecordEvaluator.FieldValue if the field is missing or nil.
+// The comparison or the term containing the field evaluates to false.
+var ErrNoValue = xerrors.New("field has no value")
+
+// RecordEvaluator is implemented by evaluators of records whose fields are referred to by paths such as `Author.Name`.
+type RecordEvaluator interface {
+	// FieldValue returns the value of the field at path, or ErrNoValue if the field has no value.
+	FieldValue(path []string) (Value, error)
+	// FieldContains returns true if the field at path contains v, such as an element of a list.
+	FieldContains(path []string, v Value) (bool, error)
+}
+
+func recordEvaluatorOf(evaluator Evaluator, n *FieldRef) (RecordEvaluator, error) {
+	e, ok := evaluator.(RecordEvaluator)
+	if !ok {
+		return nil, &FieldError{
+			Loc:   n.Loc,
+			Field: strings.Join(n.Path, "."),
+			Err:   xerrors.Errorf("Evaluator %T does not implement RecordEvaluator", evaluator),
+		}
+	}
+	return e, nil
+}
+
+// evalFieldRef returns the value of the field n.
+func evalFieldRef(evaluator Evaluator, n *FieldRef) (Value, error) {
+	e, err := recordEvaluatorOf(evaluator, n)
+	if err != nil {
+		return Value{}, err
+	}
+	v, err := e.FieldValue(n.Path)
+	if err == nil {
+		switch v.Type {
+		case TypeString, TypeInt, TypeFloat, TypeBool:
+			return v, nil
+		}
+		err = xerrors.Errorf("Evaluator returned a value of type '%s'", v.Type)
+	}
+	ferr := &FieldError{
+		Loc:   n.Loc,
+		Field: strings.Join(n.Path, "."),
+		Err:   err,
+	}
+	if xerrors.Is(err, ErrNoValue) {
+		return Value{}, &falseError{node: n, err: ferr}
+	}
+	return Value{}, ferr
+}
+
+// evalFieldContains evaluates `n contains v`.
+func evalFieldContains(evaluator Evaluator, n *FieldRef, v Value) (bool, error) {
+	e, err := recordEvaluatorOf(evaluator, n)
+	if err != nil {
+		return false, err
+	}
+	r, err := e.FieldContains(n.Path, v)
+	if xerrors.Is(err, ErrNoValue) {
+		return false, nil
+	}
+	if err != nil {
+		return false, &FieldError{
+			Loc:   n.Loc,
+			Field: strings.Join(n.Path, "."),
+			Err:   err,
+		}
+	}
+	return r, nil
+}
+
+// containsField returns the field if n is `field contains Y`, which the evaluator evaluates.
+func containsField(n *BinaryOp) (*FieldRef, bool) {
+	if n.Op != OpContains {
+		return nil, false
+	}
+	ref, ok := n.X.(*FieldRef)
+	return ref, ok
+}
@@ -0,0 +1,188 @@
+package suzuitoql
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+type testRecordEvaluator struct {
+	testEvaluator
+	fields map[string]string
+}
+
+func (e *testRecordEvaluator) EvalFieldFloat(field string, v float64) (bool, error) {
+	return e.EvalFieldString(field, FloatValue(v).String())
+}
+
+func (e *testRecordEvaluator) EvalFieldInt(field string, v int64) (bool, error) {
+	return e.EvalFieldString(field, IntValue(v).String())
+}
+
+func (e *testRecordEvaluator) EvalFieldString(field string, v string) (bool, error) {
+	t := testEvaluator{text: e.fields[field]}
+	return t.EvalString(v)
+}
+
+func TestFieldTerm(t *testing.T) {
+	schema := &Schema{Fields: []string{"title", "body", "year"}}
+	evaluator := &testRecordEvaluator{
+		testEvaluator: testEvaluator{text: "セロ弾きのゴーシュ ゴーシュは町の活動写真館でセロを弾く係りでした。"},
+		fields: map[string]string{
+			"title": "セロ弾きのゴーシュ",
+			"body":  "ゴーシュは町の活動写真館でセロを弾く係りでした。",
+			"year":  "1934",
+		},
+	}
+	testCases := []struct {
+		query       string
+		formatted   string
+		expected    bool
+		expectedErr string
+	}{
+		{query: `title:"ゴーシュ" && body:"セロ"`, formatted: `title:"ゴーシュ" && body:"セロ"`, expected: true},
+		{query: `title : 'ねずみ' || !body:"ねずみ"`, formatted: `title:"ねずみ" || !body:"ねずみ"`, expected: true},
+		{query: `year:1934 && !year:-1934`, formatted: `year:1934 && !year:-1934`, expected: true},
+		{query: `(title:"ゴーシュ") == body:"ねずみ"`, formatted: `title:"ゴーシュ" == body:"ねずみ"`, expected: false},
+		{query: `author:"宮沢賢治" || titl:"セロ"`, expectedErr: "1:1: unknown field 'author' (and 1 more errors)"},
+		{query: `title:`, expectedErr: "Cannot ParseExpr : 1:7: expected term after 'title:', found EOF"},
+		{query: `title:true`, expectedErr: "Cannot ParseExpr : 1:7: expected term after 'title:', found 'true'"},
+		{query: `year:-"1934"`, expectedErr: "Cannot ParseExpr : 1:7: expected number after '-', found '\"1934\"'"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.query, func(t *testing.T) {
+			f, err := Compile(tC.query, schema)
+			if err != nil {
+				if errorMessage(err) != tC.expectedErr {
+					t.Errorf("expected error %q but %q", tC.expectedErr, errorMessage(err))
+				}
+				return
+			}
+			if tC.expectedErr != "" {
+				t.Fatalf("expected error %q but nil", tC.expectedErr)
+			}
+			if f.String() != tC.formatted {
+				t.Errorf("expected %s but %s", tC.formatted, f.String())
+			}
+			actual, err := f.Eval(evaluator)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e, err := f.Explain(evaluator)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tC.expected || e.Result != tC.expected {
+				t.Errorf("expected %v but %v, explained %v", tC.expected, actual, e.Result)
+			}
+		})
+	}
+}
+
+func TestFieldTermWithoutFieldEvaluator(t *testing.T) {
+	f, err := GenerateFilterFromString(`"セロ" && title:"ゴーシュ"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "1:9: cannot evaluate field 'title': Evaluator *suzuitoql.testEvaluator does not implement FieldEvaluator"
+	if _, err := f.Eval(&testEvaluator{text: "セロ"}); errorMessage(err) != expected {
+		t.Errorf("expected error %q but %q", expected, errorMessage(err))
+	}
+}
+
+// failingRecordEvaluator fails to evaluate any field term.
+type failingRecordEvaluator struct {
+	testRecordEvaluator
+}
+
+func (e *failingRecordEvaluator) EvalFieldString(field string, v string) (bool, error) {
+	return false, errBoom
+}
+
+func TestFieldTermError(t *testing.T) {
+	query := `"セロ" &&
+	x:"a"`
+	f, err := GenerateFilterFromString(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.Eval(&failingRecordEvaluator{testRecordEvaluator{testEvaluator: testEvaluator{text: "セロ"}}})
+	var ferr *FieldError
+	if !xerrors.As(err, &ferr) || !xerrors.Is(err, errBoom) {
+		t.Fatalf("expected FieldError wrapping boom but %v", err)
+	}
+	expected := "2:2: cannot evaluate field 'x': boom\n" +
+		"\tx:\"a\"\n" +
+		"\t^^^^^"
+	if actual := FormatError(query, err); actual != expected {
+		t.Errorf("expected\n%s\nbut\n%s", expected, actual)
+	}
+}
+
+func TestMatchFieldTerm(t *testing.T) {
+	f, err := GenerateFilterFromString(`title:"ゴーシュ" || "ゴーシュ"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := f.Match(&testRecordEvaluator{fields: map[string]string{"title": "ゴーシュ"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Terms) != 1 || m.Terms[0].Node != f.Expr().(*BinaryOp).X || m.Terms[0].Value != StringValue("ゴーシュ") {
+		t.Errorf("unexpected terms %+v", m.Terms)
+	}
+}
+
+func (e *testRecordEvaluator) FieldValue(path []string) (Value, error) {
+	if path[0] == "year" {
+		return IntValue(1934), nil
+	}
+	return StringValue(e.fields[strings.Join(path, ".")]), nil
+}
+
+func (e *testRecordEvaluator) FieldContains(path []string, v Value) (bool, error) {
+	return strings.Contains(e.fields[strings.Join(path, ".")], v.Str), nil
+}
+
+func TestFieldRef(t *testing.T) {
+	evaluator := &testRecordEvaluator{
+		fields: map[string]string{
+			"title":       "セロ弾きのゴーシュ",
+			"author.name": "宮沢賢治",
+		},
+	}
+	testCases := []struct {
+		query     string
+		formatted string
+		expected  bool
+	}{
+		{query: `author.name == "宮沢賢治" && year > 1900`, formatted: `author.name == "宮沢賢治" && year > 1900`, expected: true},
+		{query: `title contains "ゴーシュ"`, formatted: `title contains "ゴーシュ"`, expected: true},
+		{query: `(author . name contains "ねずみ") == false`, formatted: `author.name contains "ねずみ" == false`, expected: true},
+		{query: `"宮沢賢治" contains author.name && !("宮沢" contains "賢治")`, formatted: `"宮沢賢治" contains author.name && !("宮沢" contains "賢治")`, expected: true},
+		{query: `author.name:"賢治" || title`, formatted: `author.name:"賢治" || title`, expected: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.query, func(t *testing.T) {
+			f, err := GenerateFilterFromString(tC.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.String() != tC.formatted {
+				t.Errorf("expected %s but %s", tC.formatted, f.String())
+			}
+			actual, err := f.Eval(evaluator)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e, err := f.Explain(evaluator)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tC.expected || e.Result != tC.expected {
+				t.Errorf("expected %v but %v, explained %v", tC.expected, actual, e.Result)
+			}
+		})
+	}
+}
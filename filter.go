@@ -1,473 +1,215 @@
 package suzuitoql
 
 import (
-	"fmt"
-	"go/ast"
-	"go/format"
-	"go/parser"
-	"go/token"
-	"reflect"
+	"context"
 	"regexp"
-	"strconv"
-	"strings"
+	"sync"
 
 	"golang.org/x/xerrors"
 )
 
-var newlineRegexp = regexp.MustCompile(`\r?\n`)
-
-func GenerateFilterFromString(expr string) (*Filter, error) {
-	norm := newlineRegexp.ReplaceAllString(expr, "")
-	b, err := format.Source([]byte(norm))
-	if err != nil {
-		return nil, err
-	}
-	root, err := parser.ParseExpr(string(b))
+func GenerateFilterFromString(expr string, opts ...Option) (*Filter, error) {
+	o := newOptions(opts)
+	root, err := parseExpr(expr, o.limits)
 	if err != nil {
-		return nil, xerrors.Errorf("Cannot ParseExpr %s : %w", expr, err)
+		return nil, xerrors.Errorf("Cannot ParseExpr : %w", err)
 	}
-	return GenerateFilter(b, root)
+	return newFilter(expr, root, o)
 }
 
-func GenerateFilter(source []byte, root ast.Expr) (*Filter, error) {
-	visitor := visitorExpression2{
-		Err:   nil,
-		nodes: []ast.Node{},
-		Stack: []ast.Node{},
-	}
-	ast.Walk(&visitor, root)
-	elems, err := newElements(source, visitor.Stack)
-	if err != nil {
+// NewFilter generates a filter from a syntax tree, such as one returned by ParseExpr or Rewrite.
+// Spans of errors returned by the filter point into the query the tree was parsed from.
+func NewFilter(root Expr, opts ...Option) (*Filter, error) {
+	if err := validateExpr(root); err != nil {
 		return nil, xerrors.Errorf(": %w", err)
 	}
-	return &Filter{
-		elems: elems,
-	}, visitor.Err
-}
-
-type visitorExpression2 struct {
-	Err    error
-	nodes  []ast.Node
-	Stack  []ast.Node
-	Source []byte
-}
-
-func (v *visitorExpression2) Visit(current ast.Node) ast.Visitor {
-	if current != nil {
-		// Process after entered
-		v.nodes = append(v.nodes, current)
-		if err := v.onEnter(current); err != nil {
-			v.Err = err
-			return nil
-		}
-		return v
-	}
-	// Process before exited
-	current = v.nodes[len(v.nodes)-1]
-	v.nodes = v.nodes[:len(v.nodes)-1]
-	if err := v.onExit(current); err != nil {
-		v.Err = err
-		return nil
-	}
-	return nil
-}
-
-func (v *visitorExpression2) onEnter(current ast.Node) error {
-	return nil
-}
-
-func (v *visitorExpression2) onExit(current ast.Node) error {
-	switch n := current.(type) {
-	case *ast.BinaryExpr:
-		if n.Op != token.LAND && n.Op != token.LOR {
-			return xerrors.Errorf("Unsupported BinaryExpr: %s", n.Op)
-		}
-		v.Stack = append(v.Stack, current)
-	case *ast.BasicLit:
-		if n.Kind != token.STRING && n.Kind != token.INT && n.Kind != token.FLOAT {
-			return xerrors.Errorf("Unsupported BasecLit : %s %s", n.Kind, n.Value)
-		}
-		v.Stack = append(v.Stack, current)
-	case *ast.UnaryExpr:
-		if n.Op != token.SUB {
-			return xerrors.Errorf("Unsupported UnaryExpr : %s %s", n.Op)
-		}
-		v.Stack = append(v.Stack, current)
-	case *ast.CallExpr:
-		v.Stack = append(v.Stack, current)
-	case *ast.Ident:
-		if n.String() == "true" || n.String() == "false" {
-			v.Stack = append(v.Stack, current)
-		}
-	default:
-	}
-	return nil
-}
-
-type elementType string
-
-const (
-	elementTypeOpBinAnd  elementType = "and"
-	elementTypeOpBinOr   elementType = "or"
-	elementTypeOpMinus   elementType = "-"
-	elementTypeOpFunc    elementType = "func"
-	elementTypeLitString elementType = "string"
-	elementTypeLitInt    elementType = "int"
-	elementTypeLitFloat  elementType = "float"
-	elementTypeLitBool   elementType = "bool"
-)
-
-type element struct {
-	Type        elementType
-	FuncName    string
-	FuncArgs    int
-	ValueString string
-	ValueInt    int64
-	ValueFloat  float64
-	ValueBool   bool
+	return newFilter("", root, newOptions(opts))
 }
 
-func (e *element) String() string {
-	switch e.Type {
-	case elementTypeOpBinAnd:
-		return string(e.Type)
-	case elementTypeOpBinOr:
-		return string(e.Type)
-	case elementTypeOpMinus:
-		return string(e.Type)
-	case elementTypeOpFunc:
-		return fmt.Sprintf("%s(%d)", e.FuncName, e.FuncArgs)
-	case elementTypeLitString:
-		return e.ValueString
-	case elementTypeLitInt:
-		return fmt.Sprintf("%d", e.ValueInt)
-	case elementTypeLitFloat:
-		return fmt.Sprintf("%f", e.ValueFloat)
-	case elementTypeLitBool:
-		return fmt.Sprintf("%v", e.ValueBool)
+func newFilter(source string, root Expr, o options) (*Filter, error) {
+	if err := checkLimits(root, o.limits); err != nil {
+		return nil, err
 	}
-	return fmt.Sprintf("%+v", *e)
-}
-
-func (e *element) Value() (reflect.Value, error) {
-	var v reflect.Value
-	switch e.Type {
-	case elementTypeLitFloat:
-		v = reflect.ValueOf(e.ValueFloat)
-	case elementTypeLitBool:
-		v = reflect.ValueOf(e.ValueBool)
-	case elementTypeLitInt:
-		v = reflect.ValueOf(e.ValueInt)
-	case elementTypeLitString:
-		v = reflect.ValueOf(e.ValueString)
-	default:
-		return v, xerrors.Errorf("Cannot new value of type '%s'", e.Type)
+	regexps, err := compileRegexps(root)
+	if err != nil {
+		return nil, err
 	}
-	return v, nil
-}
-
-type elements []element
-
-func (e *elements) String() string {
-	r := []string{}
-	for _, v := range *e {
-		r = append(r, v.String())
+	if err := checkFunctions(root, o.functions); err != nil {
+		return nil, err
 	}
-	return strings.Join(r, ",")
-}
-
-func newElements(source []byte, nodes []ast.Node) (*elements, error) {
-	r := elements{}
-	for _, node := range nodes {
-		e, err := newElement(source, node)
-		if err != nil {
-			return nil, xerrors.Errorf(": %w", err)
+	return &Filter{
+		source:  source,
+		root:    root,
+		regexps: regexps,
+		prog:    newProgram(root, o, regexps, false),
+		opts:    o,
+	}, nil
+}
+
+// checkFunctions returns *UnknownFunctionError if root calls a function which is not registered to functions.
+func checkFunctions(root Expr, functions *FunctionRegistry) error {
+	var err error
+	Inspect(root, func(node Expr) bool {
+		call, ok := node.(*Call)
+		if !ok || call.Name == matchFunctionName || err != nil {
+			return err == nil
+		}
+		if _, exists := functions.Lookup(call.Name); !exists {
+			err = &UnknownFunctionError{
+				Loc:  call.NameLoc,
+				Name: call.Name,
+			}
 		}
-		r = append(r, *e)
-	}
-	return &r, nil
+		return err == nil
+	})
+	return err
 }
 
-func newElementByValue(v reflect.Value) (*element, error) {
-	switch v.Type().Name() {
-	case "string":
-		return &element{
-			Type:        elementTypeLitString,
-			ValueString: v.String(),
-		}, nil
-	case "int":
-		return &element{
-			Type:     elementTypeLitInt,
-			ValueInt: v.Int(),
-		}, nil
-	case "int64":
-		return &element{
-			Type:     elementTypeLitInt,
-			ValueInt: v.Int(),
-		}, nil
-	case "float":
-		return &element{
-			Type:       elementTypeLitFloat,
-			ValueFloat: v.Float(),
-		}, nil
-	case "float64":
-		return &element{
-			Type:       elementTypeLitFloat,
-			ValueFloat: v.Float(),
-		}, nil
-	case "bool":
-		return &element{
-			Type:      elementTypeLitBool,
-			ValueBool: v.Bool(),
-		}, nil
-	}
-	return nil, xerrors.Errorf("Cannot element from Value(%s)", v.Type().Name())
+type Filter struct {
+	source string
+	root   Expr
+	// regexps are compiled patterns of Match calls
+	regexps map[*Call]*regexp.Regexp
+	prog    *program
+	opts    options
+	// traced is prog compiled for tracing, which is compiled by the first call of Explain
+	traced     *program
+	tracedOnce sync.Once
 }
 
-func newElement(source []byte, node ast.Node) (*element, error) {
-	switch n := node.(type) {
-	case *ast.BinaryExpr:
-		if n.Op == token.LAND {
-			return &element{
-				Type: elementTypeOpBinAnd,
-			}, nil
-		}
-		if n.Op == token.LOR {
-			return &element{
-				Type: elementTypeOpBinOr,
-			}, nil
-		}
-		return nil, xerrors.Errorf("Unsupported BinaryExpr: %s", n.Op)
-	case *ast.BasicLit:
-		if n.Kind == token.STRING {
-			return &element{
-				Type:        elementTypeLitString,
-				ValueString: strings.TrimSuffix(strings.TrimPrefix(n.Value, `"`), `"`),
-			}, nil
-		}
-		if n.Kind == token.INT {
-			v, err := strconv.ParseInt(n.Value, 10, 64)
-			if err != nil {
-				return nil, xerrors.Errorf("Cannot convert str to int64 : %w", err)
-			}
-			return &element{
-				Type:     elementTypeLitInt,
-				ValueInt: v,
-			}, nil
-		}
-		if n.Kind == token.FLOAT {
-			v, err := strconv.ParseFloat(n.Value, 64)
-			if err != nil {
-				return nil, xerrors.Errorf("Cannot convert str to float64 : %w", err)
-			}
-			return &element{
-				Type:       elementTypeLitFloat,
-				ValueFloat: v,
-			}, nil
-		}
-		return nil, xerrors.Errorf("Unsupported BasecLit : %s %s", n.Kind, n.Value)
-	case *ast.UnaryExpr:
-		if n.Op == token.SUB {
-			return &element{
-				Type: elementTypeOpMinus,
-			}, nil
-		}
-		return nil, xerrors.Errorf("Unsupported UnaryExpr : %s %s", n.Op)
-	case *ast.CallExpr:
-		return &element{
-			Type:     elementTypeOpFunc,
-			FuncName: string(source[n.Fun.Pos()-1 : n.Fun.End()-1]),
-			FuncArgs: len(n.Args),
-		}, nil
-	case *ast.Ident:
-		if n.String() == "true" {
-			return &element{
-				Type:      elementTypeLitBool,
-				ValueBool: true,
-			}, nil
-		}
-		if n.String() == "false" {
-			return &element{
-				Type:      elementTypeLitBool,
-				ValueBool: false,
-			}, nil
-		}
-		return nil, xerrors.Errorf("Unsupported UnaryExpr : %+v", n)
-	}
-	return nil, xerrors.Errorf("Unsupported %s : %+v", reflect.TypeOf(node), node)
+// Source returns the query the filter was generated from.
+// Spans of errors returned by the filter point into it.
+// It is empty if the filter was generated by NewFilter.
+func (f *Filter) Source() string {
+	return f.source
 }
 
-type Filter struct {
-	elems *elements
+// Expr returns the syntax tree of the filter. It must not be modified. Use Rewrite to modify it.
+func (f *Filter) Expr() Expr {
+	return f.root
 }
 
 func (f *Filter) Eval(
 	evaluator Evaluator,
 ) (bool, error) {
-	stack := elements{}
-	for i := range *f.elems {
-		elem := (*f.elems)[i]
-		switch elem.Type {
-		case elementTypeLitString:
-			stack = append(stack, elem)
-		case elementTypeLitInt:
-			stack = append(stack, elem)
-		case elementTypeLitFloat:
-			stack = append(stack, elem)
-		case elementTypeLitBool:
-			stack = append(stack, elem)
-		case elementTypeOpBinAnd, elementTypeOpBinOr:
-			if len(stack) < 2 {
-				return false, xerrors.Errorf("Stack must be larger than 2 for %s op", elem.Type)
-			}
-			args := elements{
-				stack[len(stack)-2],
-				stack[len(stack)-1],
-			}
-			stack = stack[:len(stack)-2]
-			// var err error
-			var result *element
-			switch elem.Type {
-			case elementTypeOpBinAnd:
-				bresult, err := evalAnd(&args[0], &args[1], evaluator)
-				if err != nil {
-					return false, xerrors.Errorf(": %w", err)
-				}
-				result = &element{
-					Type:      elementTypeLitBool,
-					ValueBool: bresult,
-				}
-			case elementTypeOpBinOr:
-				bresult, err := evalOr(&args[0], &args[1], evaluator)
-				if err != nil {
-					return false, xerrors.Errorf(": %w", err)
-				}
-				result = &element{
-					Type:      elementTypeLitBool,
-					ValueBool: bresult,
-				}
-			default:
-				return false, xerrors.Errorf("Unsupport op %+v", elem.Type)
-			}
-			stack = append(stack, *result)
-		case elementTypeOpMinus:
-			if len(stack) < 1 {
-				return false, xerrors.Errorf("Stack must be larger than 1 for %s op", elem.Type)
-			}
-			args := elements{
-				stack[len(stack)-1],
-			}
-			stack = stack[:len(stack)-1]
-			if args[0].Type != elementTypeLitInt && args[0].Type != elementTypeLitFloat {
-				return false, xerrors.Errorf("Cannot apply minus for %+v", args[0])
-			}
-			stack = append(stack, element{
-				Type:       args[0].Type,
-				ValueInt:   -args[0].ValueInt,
-				ValueFloat: -args[0].ValueFloat,
-			})
-		case elementTypeOpFunc:
-			if len(stack) < elem.FuncArgs {
-				return false, xerrors.Errorf("Stack must be larger than %d for function", elem.FuncArgs)
-			}
-			args := elements{}
-			for i := 0; i < elem.FuncArgs; i++ {
-				args = append(args, stack[len(stack)-elem.FuncArgs+i])
-			}
-			stack = stack[:len(stack)-elem.FuncArgs]
-			result, err := evalFunc(evaluator, elem.FuncName, args...)
-			if err != nil {
-				return false, xerrors.Errorf(": %w", err)
-			}
-			stack = append(stack, *result)
-		}
-	}
-	return stack[0].ValueBool, nil
+	return f.EvalContext(context.Background(), evaluator)
 }
 
-func evalAnd(
-	a *element,
-	b *element,
+// EvalContext is Eval which stops when ctx is done, returning *CanceledError.
+// ctx is passed to functions which take context.
+// Filters are safe for concurrent use by multiple goroutines.
+func (f *Filter) EvalContext(
+	ctx context.Context,
 	evaluator Evaluator,
 ) (bool, error) {
-	aResult, err := evalElement(a, evaluator)
-	if err != nil {
-		return false, xerrors.Errorf("Cannot evalElement : %w", err)
-	}
-	bResult, err := evalElement(b, evaluator)
-	if err != nil {
-		return false, xerrors.Errorf("Cannot evalElement : %w", err)
-	}
-	return aResult && bResult, nil
+	return f.evalWith(ctx, evaluator, f.prog.eval)
 }
 
-func evalOr(
-	a *element,
-	b *element,
+// evalWith evaluates the filter by eval, which runs the compiled program.
+func (f *Filter) evalWith(
+	ctx context.Context,
 	evaluator Evaluator,
+	eval func(ctx context.Context, evaluator Evaluator) (bool, error),
 ) (bool, error) {
-	aResult, err := evalElement(a, evaluator)
-	if err != nil {
-		return false, xerrors.Errorf("Cannot evalElement : %w", err)
+	parent := ctx
+	if f.opts.limits.MaxEvalTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.opts.limits.MaxEvalTime)
+		defer cancel()
 	}
-	bResult, err := evalElement(b, evaluator)
-	if err != nil {
-		return false, xerrors.Errorf("Cannot evalElement : %w", err)
+	result, err := eval(ctx, evaluator)
+	if err == nil {
+		return result, nil
+	}
+	var cerr *CanceledError
+	if !xerrors.As(err, &cerr) {
+		return false, err
+	}
+	if parent.Err() == nil {
+		return false, &EvalTimeLimitError{
+			Loc: cerr.Loc,
+			Max: f.opts.limits.MaxEvalTime,
+		}
+	}
+	return false, &CanceledError{
+		Loc: cerr.Loc,
+		Err: parent.Err(),
 	}
-	return aResult || bResult, nil
 }
 
-func evalElement(
-	v *element,
+// callFunction calls fn with args after converting args into its param types.
+// args is modified by the conversion.
+func callFunction(
+	ctx context.Context,
 	evaluator Evaluator,
-) (bool, error) {
-	switch v.Type {
-	case elementTypeLitBool:
-		return v.ValueBool, nil
-	case elementTypeLitFloat:
-		return evaluator.EvalFloat(v.ValueFloat)
-	case elementTypeLitInt:
-		return evaluator.EvalInt(v.ValueInt)
-	case elementTypeLitString:
-		return evaluator.EvalString(v.ValueString)
+	fn *Function,
+	call *Call,
+	args []Value,
+	policy FunctionErrorPolicy,
+) (Value, error) {
+	if len(fn.Params) != len(args) {
+		return Value{}, &ArgumentCountError{
+			Loc:      call.Loc,
+			Func:     fn.Name,
+			Expected: len(fn.Params),
+			Actual:   len(args),
+		}
 	}
-	return false, xerrors.Errorf("Cannot eval %s", v.Type)
-}
-
-func evalFunc(evaluator Evaluator, funcName string, args ...element) (result *element, err error) {
-	et := reflect.TypeOf(evaluator)
-	method, exists := et.MethodByName(funcName)
-	if !exists {
-		return nil, xerrors.Errorf("Method is not found '%s'", funcName)
+	for i, arg := range args {
+		v, ok := convertValue(arg, fn.Params[i])
+		if !ok {
+			return Value{}, &ArgumentTypeError{
+				Loc:      call.Args[i].Span(),
+				Func:     fn.Name,
+				Index:    i,
+				Expected: string(fn.Params[i]),
+				Actual:   string(arg.Type),
+			}
+		}
+		args[i] = v
 	}
-	values := []reflect.Value{
-		reflect.ValueOf(evaluator),
+	newCallError := func(err error) error {
+		return &FunctionCallError{
+			Loc:  call.Loc,
+			Func: fn.Name,
+			Args: append([]Value{}, args...),
+			Err:  err,
+		}
 	}
-	for i, arg := range args {
-		v, err := arg.Value()
-		if err != nil {
-			return nil, xerrors.Errorf(
-				"Arg %d of function %s is not value",
-				i,
-				funcName,
-			)
+	var v Value
+	var err error
+	if fn.CallContext != nil {
+		v, err = fn.CallContext(ctx, evaluator, args)
+	} else {
+		v, err = fn.Call(evaluator, args)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return Value{}, &CanceledError{
+				Loc: call.Loc,
+				Err: ctx.Err(),
+			}
 		}
-		values = append(values, v)
+		if policy == FunctionErrorAsFalse {
+			return Value{}, &falseError{node: call, err: newCallError(err)}
+		}
+		return Value{}, newCallError(err)
+	}
+	if v.Type != fn.Result {
+		return Value{}, newCallError(xerrors.Errorf("Function returned %s but its result type is %s", v.Type, fn.Result))
 	}
-	results := method.Func.Call(values)
-	if len(results) != 2 {
-		return nil, xerrors.Errorf(
-			"Number of function %s's returned value must be 2 : %d",
-			len(results),
-		)
+	return v, nil
+}
+
+// convertValue converts v into t. Only int can be converted into float.
+func convertValue(v Value, t Type) (Value, bool) {
+	if v.Type == t {
+		return v, true
 	}
-	valueResult := results[0]
-	valueErr := results[1]
-	if !valueErr.IsNil() {
-		// FIXME
-		return nil, xerrors.Errorf("Not impl")
+	if v.Type == TypeInt && t == TypeFloat {
+		return FloatValue(float64(v.Int)), true
 	}
-	return newElementByValue(valueResult)
+	return v, false
 }
 
 type Evaluator interface {
@@ -1,67 +1,56 @@
-package suzuitoql
+package suzuitoql_test
 
-/*
-func TestValidateFilter(t *testing.T) {
-	testCases := []struct {
-		desc        string
-		input       interface{}
-		expectedErr string
-	}{
-		{
-			desc: "",
-			input: func(topic *entities.Topic) bool {
-				return false
-			},
-		},
-		{
-			desc: "Invalid function: Empty arguments",
-			input: func() bool {
-				return false
-			},
-			expectedErr: "ErrInvalidFilter: Number of arguments must be larger than 1",
-		},
-		{
-			desc: "Invalid function: Arg0 is not topic",
-			input: func(a int) bool {
-				return false
-			},
-			expectedErr: "ErrInvalidFilter: Arg0 is not *entities.Topic",
-		},
-		{
-			desc: "Number of return values must be 1",
-			input: func(topic *entities.Topic) {
-			},
-			expectedErr: "ErrInvalidFilter: Number of return values must be 1",
-		},
-		{
-			desc: "Return value must be bool",
-			input: func(topic *entities.Topic) int {
-				return 1
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suzuito/suzuitoql"
+	"github.com/suzuito/suzuitoql/evalimpl"
+)
+
+// topicEvaluator evaluates every term as true.
+type topicEvaluator struct{}
+
+func (e *topicEvaluator) EvalFloat(v float64) (bool, error) { return true, nil }
+func (e *topicEvaluator) EvalInt(v int64) (bool, error)     { return true, nil }
+func (e *topicEvaluator) EvalString(v string) (bool, error) { return true, nil }
+
+func newTopicFunctions(t testing.TB) *suzuitoql.FunctionRegistry {
+	r := suzuitoql.NewFunctionRegistry()
+	constant := func(name string, result bool, params ...suzuitoql.Type) suzuitoql.Function {
+		return suzuitoql.Function{
+			Name:   name,
+			Params: params,
+			Result: suzuitoql.TypeBool,
+			Call: func(evaluator suzuitoql.Evaluator, args []suzuitoql.Value) (suzuitoql.Value, error) {
+				return suzuitoql.BoolValue(result), nil
 			},
-			expectedErr: "ErrInvalidFilter: Return value must be bool",
-		},
-		{
-			desc:        "Not function",
-			input:       1,
-			expectedErr: "ErrInvalidFilter: Not function",
-		},
+		}
 	}
-	for _, tC := range testCases {
-		t.Run(tC.desc, func(t *testing.T) {
-			err := validateFilter(tC.input)
-			if err != nil {
-				assert.Equal(t, tC.expectedErr, err.Error())
-				return
-			}
-		})
+	fns := []suzuitoql.Function{
+		constant("mustTrueWithArg1", true, suzuitoql.TypeString),
+		constant("mustFalseWithArg1", false, suzuitoql.TypeString),
+		constant("mustTrueWithIntArg1", true, suzuitoql.TypeInt),
+		constant("mustTrueWithBoolArg1", true, suzuitoql.TypeBool),
+		constant("mustTrueWithFloatArg1", true, suzuitoql.TypeFloat),
+		constant("mustTrueWithoutArg", true),
 	}
+	for _, fn := range fns {
+		if err := r.Register(fn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
 }
 
 func TestFilter(t *testing.T) {
 	testCases := []struct {
 		desc            string
 		inputExpression string
-		inputTopic      entities.Topic
 		expected        bool
 		expectedErr     string
 	}{
@@ -155,17 +144,15 @@ func TestFilter(t *testing.T) {
 			expected: true,
 		},
 		// 論理式の文法エラー
-		// TODO: エラーメッセージが意味不明（Go言語のシンタックスエラーがそのまま返ってくる）
-		//       式のどの部分がおかしいか？わかるとベストなんだが・・・
 		{
 			desc:            "Sytax error: empty string",
 			inputExpression: ``,
-			expectedErr:     "1:1: expected operand, found 'EOF'",
+			expectedErr:     "Cannot ParseExpr : 1:1: expected operand, found EOF",
 		},
 		{
-			desc:            "Sytax error: empty string",
+			desc:            "Sytax error: no right operand",
 			inputExpression: `mustTrueWithArg1("A") &&`,
-			expectedErr:     "3:1: expected operand, found '}'",
+			expectedErr:     "Cannot ParseExpr : 1:25: expected operand, found EOF",
 		},
 		// 評価時のエラー
 		{
@@ -173,130 +160,320 @@ func TestFilter(t *testing.T) {
 			inputExpression: `
 			mustTrueWithArg1()
 			`,
-			expectedErr: "reflect: Call with too few input arguments",
+			expectedErr: "2:4: function 'mustTrueWithArg1' takes 1 argument(s) but 0 given",
 		},
 		{
 			desc: "Evaluation error: Call with too many input arguments",
 			inputExpression: `
 			mustTrueWithArg1("a", "b")
 			`,
-			expectedErr: "reflect: Call with too many input arguments",
+			expectedErr: "2:4: function 'mustTrueWithArg1' takes 1 argument(s) but 2 given",
 		},
 		{
 			desc: "Evaluation error: Call using int as type string",
 			inputExpression: `
 			mustTrueWithArg1(1)
 			`,
-			expectedErr: "reflect: Call using int as type string",
+			expectedErr: "2:21: argument 1 of function 'mustTrueWithArg1' must be string but int given",
 		},
 		{
 			desc: "Evaluation error: Function not found",
 			inputExpression: `
 			dummyFunc("a")
 			`,
-			expectedErr: "Function not found: dummyFunc",
+			expectedErr: "2:4: unknown function 'dummyFunc'",
 		},
 		{
-			desc: "Unsupported op",
+			desc: "Evaluation error: Add bools",
 			inputExpression: `
 			mustTrueWithArg1("A") + mustTrueWithArg1("B")
 			`,
-			expectedErr: "Unsupported op: +",
+			expectedErr: "2:4: cannot apply + to bool and bool",
 		},
 	}
 	for _, tC := range testCases {
 		t.Run(tC.desc, func(t *testing.T) {
-			fg, err := GenerateFilterFromString(tC.inputExpression)
+			fg, err := suzuitoql.GenerateFilterFromString(tC.inputExpression, suzuitoql.WithFunctions(newTopicFunctions(t)))
 			if err != nil {
-				assert.Equal(t, tC.expectedErr, err.Error())
+				assertError(t, tC.expectedErr, err)
 				return
 			}
-			real, err := fg.Eval(&tC.inputTopic)
+			real, err := fg.Eval(&topicEvaluator{})
 			if err != nil {
-				assert.Equal(t, tC.expectedErr, err.Error())
+				assertError(t, tC.expectedErr, err)
 				return
 			}
-			assert.Equal(t, tC.expected, real)
+			if tC.expectedErr != "" {
+				t.Fatalf("expected error %q but nil", tC.expectedErr)
+			}
+			if real != tC.expected {
+				t.Errorf("expected %v but %v", tC.expected, real)
+			}
 		})
 	}
 }
 
+func assertError(t *testing.T, expected string, err error) {
+	t.Helper()
+	if actual := strings.TrimPrefix(err.Error(), ": "); actual != expected {
+		t.Errorf("expected error %q but %q", expected, actual)
+	}
+}
+
+// joinClauses returns a query joining n clauses by op.
+func joinClauses(clause string, op string, n int) string {
+	clauses := make([]string, n)
+	for i := range clauses {
+		clauses[i] = clause
+	}
+	return strings.Join(clauses, op)
+}
+
+// nestClauses returns a query nesting n clauses in parentheses such as `a && (a && (a))`.
+func nestClauses(clause string, op string, n int) string {
+	b := strings.Builder{}
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString(op + "(")
+		}
+		b.WriteString(clause)
+	}
+	b.WriteString(strings.Repeat(")", n-1))
+	return b.String()
+}
+
+// TestPerformance checks that large filters are evaluated without allocations.
+// The time of evaluation is measured by BenchmarkFilterEval.
 func TestPerformance(t *testing.T) {
 	testCases := []struct {
-		desc                      string
-		inputExpressions          []string
-		inputTopic                entities.Topic
-		expectedLimitMilliSeconds int
-		expected                  bool
+		desc            string
+		inputExpression string
+		expected        bool
 	}{
 		{
-			desc: "1000個の句を繋げた、1個のフィルタの評価が0.010秒以内に終わる",
-			inputExpressions: func() []string {
-				ret := []string{}
-				for i := 0; i < 1; i++ {
-					each := []string{}
-					for j := 0; j < 1000; j++ {
-						each = append(each, `mustTrueWithArg1("A")`)
-					}
-					ret = append(ret, strings.Join(each, "||"))
-				}
-				return ret
-			}(),
-			expectedLimitMilliSeconds: 10, // 0.010 seconds
-			expected:                  true,
+			desc:            "1000個の句を繋げたフィルタ",
+			inputExpression: joinClauses(`mustFalseWithArg1("A")`, "||", 1000),
+			expected:        false,
+		},
+		{
+			desc:            "10000個の句を繋げたフィルタ",
+			inputExpression: joinClauses(`mustFalseWithArg1("A")`, "||", 10000),
+			expected:        false,
+		},
+		{
+			desc:            "1000段の括弧を入れ子にしたフィルタ",
+			inputExpression: nestClauses(`mustTrueWithArg1("A")`, "&&", 1000),
+			expected:        true,
 		},
-		// {
-		// 	desc: "1000個の句を繋げた、1000個のフィルタの評価が10秒以内に終わる（このテスト長い）",
-		// 	inputExpressions: func() []string {
-		// 		ret := []string{}
-		// 		for i := 0; i < 1000; i++ {
-		// 			each := []string{}
-		// 			for j := 0; j < 1000; j++ {
-		// 				each = append(each, `mustTrueWithArg1("A")`)
-		// 			}
-		// 			ret = append(ret, strings.Join(each, "||"))
-		// 		}
-		// 		return ret
-		// 	}(),
-		// 	expectedLimitMilliSeconds: 10000, // 10 seconds
-		// 	expected:                  true,
-		// },
 	}
 	for _, tC := range testCases {
 		t.Run(tC.desc, func(t *testing.T) {
-			start := time.Now()
-			defer func() {
-				end := time.Now()
-				diff := end.UnixNano() - start.UnixNano()
-				assert.LessOrEqual(
-					t,
-					diff,
-					int64(tC.expectedLimitMilliSeconds)*int64(10e+6),
-				)
-			}()
-			fgs := []FilterGroup{}
-			for _, e := range tC.inputExpressions {
-				fg, err := Parse(e)
-				if err != nil {
-					assert.Fail(t, err.Error())
-					return
+			fg, err := suzuitoql.GenerateFilterFromString(tC.inputExpression, suzuitoql.WithFunctions(newTopicFunctions(t)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			real, err := fg.Eval(&topicEvaluator{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if real != tC.expected {
+				t.Errorf("expected %v but %v", tC.expected, real)
+			}
+			allocs := testing.AllocsPerRun(100, func() {
+				if _, err := fg.Eval(&topicEvaluator{}); err != nil {
+					t.Fatal(err)
 				}
-				fgs = append(fgs, fg)
+			})
+			if allocs > 0 {
+				t.Errorf("expected no allocations but %v per Eval", allocs)
 			}
-			for _, fg := range fgs {
-				real, err := fg.Eval(&tC.inputTopic)
+		})
+	}
+}
+
+// benchmarkQueries are evaluated to the end by EvaluatorText, because no clauses decide the results early.
+var benchmarkQueries = []struct {
+	name  string
+	query string
+}{
+	{"Terms1k", joinClauses(`"ねずみ"`, "||", 1000)},
+	{"Terms10k", joinClauses(`"ねずみ"`, "||", 10000)},
+	{"Nested1k", nestClauses(`!"ねずみ"`, "&&", 1000)},
+	{"Functions1k", joinClauses(`mustFalseWithArg1("ねずみ")`, "||", 1000)},
+	{"Functions10k", joinClauses(`mustFalseWithArg1("ねずみ")`, "||", 10000)},
+	{"NestedFunctions1k", nestClauses(`mustTrueWithArg1("ねずみ")`, "&&", 1000)},
+	{"Comparisons1k", joinClauses(`1 + 2 * 3 != 7`, "||", 1000)},
+}
+
+func BenchmarkParse(b *testing.B) {
+	for _, q := range benchmarkQueries {
+		b.Run(q.name, func(b *testing.B) {
+			functions := newTopicFunctions(b)
+			b.ReportAllocs()
+			b.SetBytes(int64(len(q.query)))
+			for i := 0; i < b.N; i++ {
+				if _, err := suzuitoql.GenerateFilterFromString(q.query, suzuitoql.WithFunctions(functions)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFilterEval(b *testing.B) {
+	text := &evalimpl.EvaluatorText{}
+	text.Init("ゴーシュは町の活動写真館でセロを弾く係りでした。")
+	evaluators := []struct {
+		name      string
+		evaluator suzuitoql.Evaluator
+	}{
+		{"Topic", &topicEvaluator{}},
+		{"Text", text},
+	}
+	for _, q := range benchmarkQueries {
+		for _, e := range evaluators {
+			b.Run(fmt.Sprintf("%s/%s", q.name, e.name), func(b *testing.B) {
+				fg, err := suzuitoql.GenerateFilterFromString(q.query, suzuitoql.WithFunctions(newTopicFunctions(b)))
 				if err != nil {
-					assert.Fail(t, err.Error())
-					return
+					b.Fatal(err)
 				}
-				assert.Equal(t, tC.expected, real)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := fg.Eval(e.evaluator); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+type contextKey string
+
+// contextEvaluator has methods which receive the context of evaluation.
+type contextEvaluator struct {
+	topicEvaluator
+	cancel context.CancelFunc
+}
+
+func (e *contextEvaluator) HasValue(ctx context.Context, key string) (bool, error) {
+	return ctx.Value(contextKey(key)) != nil, nil
+}
+
+func (e *contextEvaluator) Cancel(ctx context.Context) (bool, error) {
+	e.cancel()
+	return true, nil
+}
+
+func (e *contextEvaluator) Wait(ctx context.Context) (bool, error) {
+	<-ctx.Done()
+	return false, ctx.Err()
+}
+
+func TestEvalContext(t *testing.T) {
+	functions := suzuitoql.NewFunctionRegistry()
+	if err := functions.RegisterMethods(&contextEvaluator{}, "HasValue", "Cancel", "Wait"); err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		desc        string
+		query       string
+		newContext  func() (context.Context, context.CancelFunc)
+		limits      suzuitoql.Limits
+		expected    bool
+		expectedErr string
+		errIs       error
+	}{
+		{
+			desc:  "context is passed to methods",
+			query: `HasValue("user") && !HasValue("group")`,
+			newContext: func() (context.Context, context.CancelFunc) {
+				return context.WithCancel(context.WithValue(context.Background(), contextKey("user"), "suzuito"))
+			},
+			expected: true,
+		},
+		{
+			desc:        "canceled before evaluation",
+			query:       `"a" && "b"`,
+			newContext:  canceledContext,
+			expectedErr: "1:1: evaluation stopped: context canceled",
+			errIs:       context.Canceled,
+		},
+		{
+			desc:        "canceled during evaluation",
+			query:       `Cancel() && ("b" || "c")`,
+			newContext:  func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+			expectedErr: "1:14: evaluation stopped: context canceled",
+			errIs:       context.Canceled,
+		},
+		{
+			desc:  "canceled in a function",
+			query: `"a" && Wait()`,
+			newContext: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Millisecond)
+			},
+			expectedErr: "1:8: evaluation stopped: context deadline exceeded",
+			errIs:       context.DeadlineExceeded,
+		},
+		{
+			desc:  "deadline exceeded",
+			query: `"a" || "b"`,
+			newContext: func() (context.Context, context.CancelFunc) {
+				return context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+			},
+			expectedErr: "1:1: evaluation stopped: context deadline exceeded",
+			errIs:       context.DeadlineExceeded,
+		},
+		{
+			desc:  "deadline of the context before MaxEvalTime",
+			query: `"a" && Wait()`,
+			newContext: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Millisecond)
+			},
+			limits:      suzuitoql.Limits{MaxEvalTime: time.Hour},
+			expectedErr: "1:8: evaluation stopped: context deadline exceeded",
+			errIs:       context.DeadlineExceeded,
+		},
+		{
+			desc:  "MaxEvalTime before the deadline of the context",
+			query: `"a" && Wait()`,
+			newContext: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Hour)
+			},
+			limits:      suzuitoql.Limits{MaxEvalTime: time.Millisecond},
+			expectedErr: "1:8: evaluation takes longer than 1ms",
+			errIs:       context.DeadlineExceeded,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			f, err := suzuitoql.GenerateFilterFromString(tC.query, suzuitoql.WithFunctions(functions), suzuitoql.WithLimits(tC.limits))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := tC.newContext()
+			defer cancel()
+			actual, err := f.EvalContext(ctx, &contextEvaluator{cancel: cancel})
+			actualErr := ""
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if actualErr != tC.expectedErr {
+				t.Fatalf("expected error %q but %q", tC.expectedErr, actualErr)
+			}
+			if tC.errIs != nil && !errors.Is(err, tC.errIs) {
+				t.Errorf("expected %v in %v", tC.errIs, err)
+			}
+			if actual != tC.expected {
+				t.Errorf("expected %v but %v", tC.expected, actual)
 			}
 		})
 	}
 }
 
-func TestFilterFunctionValidation(t *testing.T) {
-	err := ValidateFilters()
-	assert.Nil(t, err)
+func canceledContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx, cancel
 }
-*/
@@ -0,0 +1,169 @@
+package suzuitoql
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// FormatOptions configures Format.
+type FormatOptions struct {
+	// Indent indents operands of && and || put on their own lines.
+	// If it is empty, the query is formatted into a single line.
+	Indent string
+}
+
+// String returns the query of f in the canonical form of a single line.
+func (f *Filter) String() string {
+	return FormatExpr(f.root, FormatOptions{})
+}
+
+// Format returns the query of f in the canonical form, which has only necessary parentheses.
+// Parsing the result generates a filter equivalent to f.
+func Format(f *Filter, opts FormatOptions) string {
+	return FormatExpr(f.root, opts)
+}
+
+// FormatExpr returns a syntax tree in the canonical form. See Format.
+func FormatExpr(node Expr, opts FormatOptions) string {
+	p := printer{indent: opts.Indent}
+	p.expr(node, 0)
+	return p.b.String()
+}
+
+const (
+	precOr = iota + 1
+	precAnd
+	precComparison
+	precAdditive
+	precMultiplicative
+	precUnary
+	precPrimary
+)
+
+func precedence(node Expr) int {
+	switch n := node.(type) {
+	case *BinaryOp:
+		switch {
+		case n.Op == OpOr:
+			return precOr
+		case n.Op == OpAnd:
+			return precAnd
+		case n.Op.IsComparison():
+			return precComparison
+		case n.Op == OpAdd || n.Op == OpSub:
+			return precAdditive
+		}
+		return precMultiplicative
+	case *UnaryOp, *Not:
+		return precUnary
+	case *Literal:
+		if isNegativeLiteral(n) {
+			return precUnary
+		}
+	}
+	return precPrimary
+}
+
+func isLogical(node Expr) bool {
+	n, ok := node.(*BinaryOp)
+	return ok && (n.Op == OpAnd || n.Op == OpOr)
+}
+
+type printer struct {
+	b      strings.Builder
+	indent string
+	depth  int
+}
+
+func (p *printer) newline() {
+	p.b.WriteString("\n")
+	p.b.WriteString(strings.Repeat(p.indent, p.depth))
+}
+
+// expr prints node which must have precedence higher than prec, otherwise it is parenthesized.
+func (p *printer) expr(node Expr, prec int) {
+	if precedence(node) > prec {
+		p.bare(node)
+		return
+	}
+	if p.indent == "" || !isLogical(node) {
+		p.b.WriteString("(")
+		p.bare(node)
+		p.b.WriteString(")")
+		return
+	}
+	p.b.WriteString("(")
+	p.depth++
+	p.newline()
+	p.bare(node)
+	p.depth--
+	p.newline()
+	p.b.WriteString(")")
+}
+
+func (p *printer) bare(node Expr) {
+	switch n := node.(type) {
+	case *BinaryOp:
+		prec := precedence(n)
+		p.expr(n.X, prec-1)
+		if p.indent != "" && isLogical(n) {
+			p.newline()
+			p.b.WriteString(string(n.Op))
+			p.newline()
+		} else {
+			p.b.WriteString(" " + string(n.Op) + " ")
+		}
+		p.expr(n.Y, prec)
+	case *UnaryOp:
+		p.b.WriteString(string(n.Op))
+		p.expr(n.X, precUnary-1)
+	case *Not:
+		p.b.WriteString("!")
+		p.expr(n.X, precUnary-1)
+	case *Call:
+		// Arguments are always in a single line
+		indent := p.indent
+		p.indent = ""
+		p.b.WriteString(n.Name)
+		p.b.WriteString("(")
+		for i, arg := range n.Args {
+			if i > 0 {
+				p.b.WriteString(", ")
+			}
+			p.expr(arg, 0)
+		}
+		p.b.WriteString(")")
+		p.indent = indent
+	case *FieldTerm:
+		p.b.WriteString(n.Field + ":" + formatValue(n.Term.Value))
+	case *FieldRef:
+		p.b.WriteString(strings.Join(n.Path, "."))
+	case *Literal:
+		p.b.WriteString(formatValue(n.Value))
+	}
+}
+
+func isNegativeLiteral(n *Literal) bool {
+	return n.Value.Type == TypeInt && n.Value.Int < 0 ||
+		n.Value.Type == TypeFloat && math.Signbit(n.Value.Float)
+}
+
+// formatValue returns a literal of v. The sign of a negative number is a part of the literal.
+func formatValue(v Value) string {
+	switch v.Type {
+	case TypeString:
+		return strconv.Quote(v.Str)
+	case TypeInt:
+		return strconv.FormatInt(v.Int, 10)
+	case TypeFloat:
+		s := strconv.FormatFloat(v.Float, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eIN") {
+			s += ".0"
+		}
+		return s
+	case TypeBool:
+		return strconv.FormatBool(v.Bool)
+	}
+	return v.String()
+}
@@ -0,0 +1,166 @@
+package suzuitoql
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// treeOf returns node as an S-expression, which shows the structure of the syntax tree without spans.
+func treeOf(node Expr) string {
+	switch n := node.(type) {
+	case *BinaryOp:
+		return fmt.Sprintf("(%s %s %s)", n.Op, treeOf(n.X), treeOf(n.Y))
+	case *UnaryOp:
+		return fmt.Sprintf("(%s %s)", n.Op, treeOf(n.X))
+	case *Not:
+		return fmt.Sprintf("(! %s)", treeOf(n.X))
+	case *Call:
+		args := make([]string, len(n.Args))
+		for i, arg := range n.Args {
+			args[i] = treeOf(arg)
+		}
+		return fmt.Sprintf("(%s %s)", n.Name, strings.Join(args, " "))
+	case *FieldTerm:
+		return fmt.Sprintf("%s:%s", n.Field, treeOf(n.Term))
+	case *FieldRef:
+		return strings.Join(n.Path, ".")
+	case *Literal:
+		return fmt.Sprintf("%s(%s)", n.Value.Type, n.Value)
+	}
+	return fmt.Sprintf("%T", node)
+}
+
+func TestFormat(t *testing.T) {
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{query: `a - (b - c)`, expected: `a - (b - c)`},
+		{query: `(a - b) - c`, expected: `a - b - c`},
+		{query: `a / (b * c) % d`, expected: `a / (b * c) % d`},
+		{query: `(a + b) * -(c + 1)`, expected: `(a + b) * -(c + 1)`},
+		{query: `(("a" || "b")) && "c"`, expected: `("a" || "b") && "c"`},
+		{query: `"a" || ("b" && "c")`, expected: `"a" || "b" && "c"`},
+		{query: `NOT ("a" && !"b")`, expected: `!("a" && !"b")`},
+		{query: `(1 < 2) == (3 > 4)`, expected: `1 < 2 == (3 > 4)`},
+		{query: `-1 - -2.5 * - -3`, expected: `-1 - -2.5 * --3`},
+		{query: `1e3 + 2.0 + 0.5`, expected: `1000.0 + 2.0 + 0.5`},
+		{query: `'ゴー"シュ' && "\t"`, expected: `"ゴー\"シュ" && "\t"`},
+		{query: `Tags contains "go" && "宮沢賢治" contains Author.name`, expected: `Tags contains "go" && "宮沢賢治" contains Author.name`},
+		{query: `(a contains "b") == false`, expected: `a contains "b" == false`},
+		{query: `a contains ("b" + "c")`, expected: `a contains "b" + "c"`},
+		{query: `title : 'ゴーシュ' && !year:-1934 || price:1.50`, expected: `title:"ゴーシュ" && !year:-1934 || price:1.5`},
+		{query: `Author.name:"賢治"`, expected: `Author.name:"賢治"`},
+		{query: `Len( ("a") , Match("^a") ) > 1`, expected: `Len("a", Match("^a")) > 1`},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.query, func(t *testing.T) {
+			root, err := ParseExpr(tC.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual := FormatExpr(root, FormatOptions{})
+			if actual != tC.expected {
+				t.Errorf("expected %s but %s", tC.expected, actual)
+			}
+			reparsed, err := ParseExpr(actual)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if treeOf(reparsed) != treeOf(root) {
+				t.Errorf("expected %s after round trip but %s", treeOf(root), treeOf(reparsed))
+			}
+		})
+	}
+}
+
+func TestFormatLiterals(t *testing.T) {
+	lit := func(v Value) *Literal {
+		return &Literal{Value: v}
+	}
+	testCases := []struct {
+		node     Expr
+		expected string
+		value    Value
+	}{
+		{node: lit(IntValue(-2)), expected: `-2`, value: IntValue(-2)},
+		{node: lit(FloatValue(-1.5)), expected: `-1.5`, value: FloatValue(-1.5)},
+		{node: lit(FloatValue(math.Copysign(0, -1))), expected: `-0.0`, value: FloatValue(0)},
+		{node: lit(IntValue(math.MinInt64)), expected: `-9223372036854775808`, value: IntValue(math.MinInt64)},
+		{
+			node:     &BinaryOp{Op: OpSub, X: lit(IntValue(1)), Y: lit(IntValue(-2))},
+			expected: `1 - -2`,
+			value:    IntValue(3),
+		},
+		{
+			node:     &BinaryOp{Op: OpMul, X: lit(IntValue(math.MinInt64)), Y: lit(IntValue(1))},
+			expected: `-9223372036854775808 * 1`,
+			value:    IntValue(math.MinInt64),
+		},
+		{
+			node:     &BinaryOp{Op: OpSub, X: lit(IntValue(0)), Y: lit(IntValue(math.MinInt64 + 1))},
+			expected: `0 - -9223372036854775807`,
+			value:    IntValue(math.MaxInt64),
+		},
+		{
+			node:     &UnaryOp{Op: OpMinus, X: lit(FloatValue(-2))},
+			expected: `--2.0`,
+			value:    FloatValue(2),
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.expected, func(t *testing.T) {
+			actual := FormatExpr(tC.node, FormatOptions{})
+			if actual != tC.expected {
+				t.Errorf("expected %s but %s", tC.expected, actual)
+			}
+			reparsed, err := ParseExpr(actual)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, err := NewFilter(&BinaryOp{Op: OpEq, X: reparsed, Y: lit(tC.value)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r, err := f.Eval(&testEvaluator{}); err != nil || !r {
+				t.Errorf("expected %s == %s but (%v, %v)", actual, tC.value, r, err)
+			}
+		})
+	}
+}
+
+func TestFormatIndent(t *testing.T) {
+	f, err := GenerateFilterFromString(`("a" || "b") && "c" || !("d" && Len("x" || "y") > 1)`, WithFunctions(newTestFunctions(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `(
+  "a"
+  ||
+  "b"
+)
+&&
+"c"
+||
+!(
+  "d"
+  &&
+  Len("x" || "y") > 1
+)`
+	actual := Format(f, FormatOptions{Indent: "  "})
+	if actual != expected {
+		t.Errorf("expected\n%s\nbut\n%s", expected, actual)
+	}
+	reparsed, err := ParseExpr(actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if treeOf(reparsed) != treeOf(f.Expr()) {
+		t.Errorf("expected %s after round trip but %s", treeOf(f.Expr()), treeOf(reparsed))
+	}
+	if s := f.String(); s != `("a" || "b") && "c" || !("d" && Len("x" || "y") > 1)` {
+		t.Errorf("expected a single line but %s", s)
+	}
+}
@@ -0,0 +1,219 @@
+package suzuitoql
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"sort"
+
+	"golang.org/x/xerrors"
+)
+
+// Function is a function callable from queries.
+// Call receives the evaluator given to Filter.Eval and arguments whose types are Params.
+// Call must return a value of type Result. It must not retain args after it returns.
+// Functions which need the context given to Filter.EvalContext set CallContext instead of Call.
+type Function struct {
+	Name        string
+	Params      []Type
+	Result      Type
+	Call        func(evaluator Evaluator, args []Value) (Value, error)
+	CallContext func(ctx context.Context, evaluator Evaluator, args []Value) (Value, error)
+}
+
+// FunctionRegistry is a set of functions callable from queries.
+// Queries can call only functions registered to the registry given by WithFunctions.
+type FunctionRegistry struct {
+	funcs map[string]*Function
+}
+
+func NewFunctionRegistry() *FunctionRegistry {
+	return &FunctionRegistry{
+		funcs: map[string]*Function{},
+	}
+}
+
+var functionNameRegexp = regexp.MustCompile(`^[A-Za-z_][0-9A-Za-z_]*$`)
+
+func (r *FunctionRegistry) Register(fn Function) error {
+	if !functionNameRegexp.MatchString(fn.Name) || fn.Name == "true" || fn.Name == "false" || fn.Name == "NOT" || fn.Name == matchFunctionName {
+		return xerrors.Errorf("Invalid function name '%s'", fn.Name)
+	}
+	if _, exists := r.funcs[fn.Name]; exists {
+		return xerrors.Errorf("Function '%s' is already registered", fn.Name)
+	}
+	for i, p := range fn.Params {
+		if !isValidType(p) {
+			return xerrors.Errorf("Param %d of function '%s' has invalid type '%s'", i, fn.Name, p)
+		}
+	}
+	if !isValidType(fn.Result) {
+		return xerrors.Errorf("Result of function '%s' has invalid type '%s'", fn.Name, fn.Result)
+	}
+	if (fn.Call == nil) == (fn.CallContext == nil) {
+		return xerrors.Errorf("Exactly one of Call and CallContext of function '%s' must be set", fn.Name)
+	}
+	fn.Params = append([]Type{}, fn.Params...)
+	r.funcs[fn.Name] = &fn
+	return nil
+}
+
+func (r *FunctionRegistry) Lookup(name string) (*Function, bool) {
+	if r == nil {
+		return nil, false
+	}
+	fn, exists := r.funcs[name]
+	return fn, exists
+}
+
+// Names returns the names of registered functions in sorted order.
+func (r *FunctionRegistry) Names() []string {
+	names := []string{}
+	for name := range r.funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisterMethods registers methods of evaluator named in allowlist as functions.
+// Each method must take arguments of string, int (on 64-bit platforms), int64, float64 or bool kinds and
+// return (T, error) where T is one of string, int, float or bool kinds.
+// Narrower kinds such as int32 cannot be arguments, because they cannot hold every value of queries.
+// Methods can take context.Context as the first argument to receive the context of evaluation.
+// The registered functions can be called only while evaluating evaluators of the same type.
+func (r *FunctionRegistry) RegisterMethods(evaluator Evaluator, allowlist ...string) error {
+	if evaluator == nil {
+		return xerrors.New("Evaluator must not be nil")
+	}
+	et := reflect.TypeOf(evaluator)
+	for _, name := range allowlist {
+		method, exists := et.MethodByName(name)
+		if !exists {
+			return xerrors.Errorf("Method '%s' is not found in %s", name, et)
+		}
+		fn, err := newMethodFunction(et, method)
+		if err != nil {
+			return xerrors.Errorf(": %w", err)
+		}
+		if err := r.Register(*fn); err != nil {
+			return xerrors.Errorf(": %w", err)
+		}
+	}
+	return nil
+}
+
+func newMethodFunction(et reflect.Type, method reflect.Method) (*Function, error) {
+	mt := method.Type
+	if mt.IsVariadic() {
+		return nil, xerrors.Errorf("Method '%s' must not be variadic", method.Name)
+	}
+	// The receiver is the first input of mt
+	first := 1
+	withContext := mt.NumIn() > 1 && mt.In(1) == contextType
+	if withContext {
+		first = 2
+	}
+	params := []Type{}
+	for i := first; i < mt.NumIn(); i++ {
+		t, ok := paramTypeOf(mt.In(i))
+		if !ok {
+			return nil, xerrors.Errorf("Arg %d of method '%s' has unsupported type %s", i-first, method.Name, mt.In(i))
+		}
+		params = append(params, t)
+	}
+	if mt.NumOut() != 2 || mt.Out(1) != errorType {
+		return nil, xerrors.Errorf("Method '%s' must return 2 values and the 2nd one must be error", method.Name)
+	}
+	result, ok := typeOfKind(mt.Out(0).Kind())
+	if !ok {
+		return nil, xerrors.Errorf("Method '%s' returns unsupported type %s", method.Name, mt.Out(0))
+	}
+	return &Function{
+		Name:   method.Name,
+		Params: params,
+		Result: result,
+		CallContext: func(ctx context.Context, evaluator Evaluator, args []Value) (Value, error) {
+			if reflect.TypeOf(evaluator) != et {
+				return Value{}, xerrors.Errorf("Evaluator must be %s but %T", et, evaluator)
+			}
+			values := []reflect.Value{
+				reflect.ValueOf(evaluator),
+			}
+			if withContext {
+				values = append(values, reflect.ValueOf(&ctx).Elem())
+			}
+			for i, arg := range args {
+				values = append(values, reflectValueOf(arg).Convert(mt.In(first+i)))
+			}
+			results := method.Func.Call(values)
+			if err := results[1]; !err.IsNil() {
+				return Value{}, err.Interface().(error)
+			}
+			return valueOfReflectValue(results[0]), nil
+		},
+	}, nil
+}
+
+var (
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+)
+
+func isValidType(t Type) bool {
+	switch t {
+	case TypeString, TypeInt, TypeFloat, TypeBool:
+		return true
+	}
+	return false
+}
+
+func typeOfKind(k reflect.Kind) (Type, bool) {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return TypeInt, true
+	case reflect.Float32, reflect.Float64:
+		return TypeFloat, true
+	case reflect.String:
+		return TypeString, true
+	case reflect.Bool:
+		return TypeBool, true
+	}
+	return "", false
+}
+
+// paramTypeOf returns the type of arguments passed to parameters of t.
+// Parameters narrower than int64 and float64 are not supported, because arguments would be truncated.
+func paramTypeOf(t reflect.Type) (Type, bool) {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Float32:
+		if t.Bits() < 64 {
+			return "", false
+		}
+	}
+	return typeOfKind(t.Kind())
+}
+
+func reflectValueOf(v Value) reflect.Value {
+	switch v.Type {
+	case TypeString:
+		return reflect.ValueOf(v.Str)
+	case TypeInt:
+		return reflect.ValueOf(v.Int)
+	case TypeFloat:
+		return reflect.ValueOf(v.Float)
+	}
+	return reflect.ValueOf(v.Bool)
+}
+
+func valueOfReflectValue(v reflect.Value) Value {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return IntValue(v.Int())
+	case reflect.Float32, reflect.Float64:
+		return FloatValue(v.Float())
+	case reflect.String:
+		return StringValue(v.String())
+	}
+	return BoolValue(v.Bool())
+}
@@ -0,0 +1,170 @@
+package suzuitoql_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/suzuito/suzuitoql"
+	"github.com/suzuito/suzuitoql/evalimpl"
+	"golang.org/x/xerrors"
+)
+
+func TestRegister(t *testing.T) {
+	call := func(evaluator suzuitoql.Evaluator, args []suzuitoql.Value) (suzuitoql.Value, error) {
+		return suzuitoql.BoolValue(true), nil
+	}
+	valid := suzuitoql.Function{
+		Name:   "Valid_1",
+		Params: []suzuitoql.Type{suzuitoql.TypeString, suzuitoql.TypeInt},
+		Result: suzuitoql.TypeBool,
+		Call:   call,
+	}
+	testCases := []struct {
+		desc        string
+		modify      func(fn *suzuitoql.Function)
+		expectedErr string
+	}{
+		{desc: "valid", modify: func(fn *suzuitoql.Function) {}},
+		{desc: "empty name", modify: func(fn *suzuitoql.Function) { fn.Name = "" }, expectedErr: "Invalid function name ''"},
+		{desc: "name starting with a digit", modify: func(fn *suzuitoql.Function) { fn.Name = "1st" }, expectedErr: "Invalid function name '1st'"},
+		{desc: "name with a symbol", modify: func(fn *suzuitoql.Function) { fn.Name = "Has-Prefix" }, expectedErr: "Invalid function name 'Has-Prefix'"},
+		{desc: "non-ASCII name", modify: func(fn *suzuitoql.Function) { fn.Name = "長さ" }, expectedErr: "Invalid function name '長さ'"},
+		{desc: "literal", modify: func(fn *suzuitoql.Function) { fn.Name = "true" }, expectedErr: "Invalid function name 'true'"},
+		{desc: "operator", modify: func(fn *suzuitoql.Function) { fn.Name = "NOT" }, expectedErr: "Invalid function name 'NOT'"},
+		{desc: "built-in function", modify: func(fn *suzuitoql.Function) { fn.Name = "Match" }, expectedErr: "Invalid function name 'Match'"},
+		{desc: "duplicated name", modify: func(fn *suzuitoql.Function) { fn.Name = "Dup" }, expectedErr: "Function 'Dup' is already registered"},
+		{desc: "invalid param type", modify: func(fn *suzuitoql.Function) { fn.Params = []suzuitoql.Type{suzuitoql.TypeString, "int32"} }, expectedErr: "Param 1 of function 'Valid_1' has invalid type 'int32'"},
+		{desc: "empty result type", modify: func(fn *suzuitoql.Function) { fn.Result = "" }, expectedErr: "Result of function 'Valid_1' has invalid type ''"},
+		{desc: "without Call", modify: func(fn *suzuitoql.Function) { fn.Call = nil }, expectedErr: "Exactly one of Call and CallContext of function 'Valid_1' must be set"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			r := suzuitoql.NewFunctionRegistry()
+			dup := valid
+			dup.Name = "Dup"
+			if err := r.Register(dup); err != nil {
+				t.Fatal(err)
+			}
+			fn := valid
+			tC.modify(&fn)
+			err := r.Register(fn)
+			actualErr := ""
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if actualErr != tC.expectedErr {
+				t.Errorf("expected error %q but %q", tC.expectedErr, actualErr)
+			}
+			if err == nil && strings.Join(r.Names(), ",") != "Dup,"+fn.Name {
+				t.Errorf("expected Dup and %s registered but %v", fn.Name, r.Names())
+			}
+		})
+	}
+}
+
+func TestRegisterMethods(t *testing.T) {
+	r := suzuitoql.NewFunctionRegistry()
+	if err := r.RegisterMethods(&evalimpl.EvaluatorText{}, "Not"); err != nil {
+		t.Fatal(err)
+	}
+	if names := strings.Join(r.Names(), ","); names != "Not" {
+		t.Errorf("expected only Not registered but %s", names)
+	}
+	testCases := []struct {
+		query       string
+		evaluator   suzuitoql.Evaluator
+		expected    bool
+		expectedErr string
+	}{
+		{query: `Not("ねずみ") && "ゴーシュ"`, evaluator: newEvaluatorText("ゴーシュ"), expected: true},
+		{query: `Init("ねずみ") || true`, evaluator: newEvaluatorText("ゴーシュ"), expectedErr: "1:1: unknown function 'Init'"},
+		{query: `EvalString("ゴーシュ")`, evaluator: newEvaluatorText("ゴーシュ"), expectedErr: "1:1: unknown function 'EvalString'"},
+		{query: `Not("ねずみ")`, evaluator: &topicEvaluator{}, expectedErr: "1:1: Not(\"ねずみ\") failed: Evaluator must be *evalimpl.EvaluatorText but *suzuitoql_test.topicEvaluator"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.query, func(t *testing.T) {
+			// Unlisted methods are unknown when the filter is generated
+			var actual bool
+			f, err := suzuitoql.GenerateFilterFromString(tC.query, suzuitoql.WithFunctions(r))
+			if err == nil {
+				actual, err = f.Eval(tC.evaluator)
+			}
+			actualErr := ""
+			if err != nil {
+				actualErr = strings.TrimPrefix(err.Error(), ": ")
+			}
+			if actualErr != tC.expectedErr {
+				t.Errorf("expected error %q but %q", tC.expectedErr, actualErr)
+			}
+			if err == nil && actual != tC.expected {
+				t.Errorf("expected %v but %v", tC.expected, actual)
+			}
+		})
+	}
+	// Compile finds unlisted methods before evaluation
+	_, err := suzuitoql.Compile(`Init("ねずみ")`, &suzuitoql.Schema{Functions: r})
+	var list suzuitoql.ErrorList
+	var uerr *suzuitoql.UnknownFunctionError
+	if !xerrors.As(err, &list) || len(list) != 1 || !xerrors.As(list[0], &uerr) || uerr.Name != "Init" {
+		t.Errorf("expected *UnknownFunctionError of Init but %v", err)
+	}
+}
+
+// narrowEvaluator has methods taking narrower kinds than values of queries.
+type narrowEvaluator struct {
+	topicEvaluator
+}
+
+func (e *narrowEvaluator) Int8(v int8) (bool, error)       { return v > 0, nil }
+func (e *narrowEvaluator) Int32(v int32) (bool, error)     { return v > 0, nil }
+func (e *narrowEvaluator) Float32(v float32) (bool, error) { return v > 0, nil }
+func (e *narrowEvaluator) Half(v int64) (int8, error)      { return int8(v / 2), nil }
+
+func TestRegisterMethodsRejectsMethods(t *testing.T) {
+	testCases := []struct {
+		evaluator   suzuitoql.Evaluator
+		method      string
+		expectedErr string
+	}{
+		{evaluator: &evalimpl.EvaluatorText{}, method: "Missing", expectedErr: "Method 'Missing' is not found in *evalimpl.EvaluatorText"},
+		{evaluator: &evalimpl.EvaluatorText{}, method: "Init", expectedErr: "Method 'Init' must return 2 values and the 2nd one must be error"},
+		{evaluator: &evalimpl.EvaluatorText{}, method: "Locate", expectedErr: "Arg 0 of method 'Locate' has unsupported type suzuitoql.Value"},
+		{evaluator: &evalimpl.EvaluatorText{}, method: "EvalRegexp", expectedErr: "Arg 0 of method 'EvalRegexp' has unsupported type *regexp.Regexp"},
+		{evaluator: &narrowEvaluator{}, method: "Int8", expectedErr: "Arg 0 of method 'Int8' has unsupported type int8"},
+		{evaluator: &narrowEvaluator{}, method: "Int32", expectedErr: "Arg 0 of method 'Int32' has unsupported type int32"},
+		{evaluator: &narrowEvaluator{}, method: "Float32", expectedErr: "Arg 0 of method 'Float32' has unsupported type float32"},
+		{evaluator: nil, method: "Not", expectedErr: "Evaluator must not be nil"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.method, func(t *testing.T) {
+			r := suzuitoql.NewFunctionRegistry()
+			err := r.RegisterMethods(tC.evaluator, tC.method)
+			if err == nil || strings.TrimPrefix(err.Error(), ": ") != tC.expectedErr {
+				t.Errorf("expected error %q but %v", tC.expectedErr, err)
+			}
+			if len(r.Names()) != 0 {
+				t.Errorf("expected no functions registered but %v", r.Names())
+			}
+		})
+	}
+}
+
+func TestRegisterMethodsWithNarrowResult(t *testing.T) {
+	r := suzuitoql.NewFunctionRegistry()
+	if err := r.RegisterMethods(&narrowEvaluator{}, "Half"); err != nil {
+		t.Fatal(err)
+	}
+	f, err := suzuitoql.GenerateFilterFromString(`Half(200) == 100`, suzuitoql.WithFunctions(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, err := f.Eval(&narrowEvaluator{}); err != nil || !r {
+		t.Errorf("expected true but (%v, %v)", r, err)
+	}
+}
+
+func newEvaluatorText(text string) *evalimpl.EvaluatorText {
+	e := &evalimpl.EvaluatorText{}
+	e.Init(text)
+	return e
+}
@@ -1,5 +1,8 @@
 module github.com/suzuito/suzuitoql
 
-go 1.16
+go 1.17
 
-require golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
+require (
+	golang.org/x/text v0.13.0
+	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1
+)
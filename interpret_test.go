@@ -0,0 +1,173 @@
+package suzuitoql
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+// interpreter evaluates a syntax tree node by node on every evaluation, as filters did before they were compiled.
+// Functions are looked up and their arguments are allocated for each call.
+// It is the baseline of BenchmarkInterpret and supports only nodes without fields.
+type interpreter struct {
+	root      Expr
+	functions *FunctionRegistry
+}
+
+func newInterpreter(f *Filter) *interpreter {
+	return &interpreter{
+		root:      f.root,
+		functions: f.opts.functions,
+	}
+}
+
+// eval evaluates the tree. It has the same signature as program.eval to be given to Filter.evalWith.
+func (in *interpreter) eval(ctx context.Context, evaluator Evaluator) (bool, error) {
+	return in.evalBool(ctx, evaluator, in.root)
+}
+
+func (in *interpreter) evalBool(ctx context.Context, evaluator Evaluator, node Expr) (bool, error) {
+	switch n := node.(type) {
+	case *BinaryOp:
+		switch n.Op {
+		case OpAnd:
+			if r, err := in.evalBool(ctx, evaluator, n.X); err != nil || !r {
+				return false, err
+			}
+			return in.evalBool(ctx, evaluator, n.Y)
+		case OpOr:
+			if r, err := in.evalBool(ctx, evaluator, n.X); err != nil || r {
+				return r, err
+			}
+			return in.evalBool(ctx, evaluator, n.Y)
+		}
+	case *Not:
+		r, err := in.evalBool(ctx, evaluator, n.X)
+		return !r, err
+	}
+	v, err := in.evalValue(ctx, evaluator, node)
+	if err != nil {
+		return false, err
+	}
+	var result bool
+	switch v.Type {
+	case TypeBool:
+		return v.Bool, nil
+	case TypeFloat:
+		result, err = evaluator.EvalFloat(v.Float)
+	case TypeInt:
+		result, err = evaluator.EvalInt(v.Int)
+	case TypeString:
+		result, err = evaluator.EvalString(v.Str)
+	}
+	if err != nil {
+		return false, &TermError{
+			Loc:   node.Span(),
+			Value: v,
+			Err:   err,
+		}
+	}
+	return result, nil
+}
+
+func (in *interpreter) evalValue(ctx context.Context, evaluator Evaluator, node Expr) (Value, error) {
+	switch n := node.(type) {
+	case *Literal:
+		return n.Value, nil
+	case *UnaryOp:
+		v, err := in.evalValue(ctx, evaluator, n.X)
+		if err != nil {
+			return Value{}, err
+		}
+		if !isNumericType(v.Type) {
+			return Value{}, &OperandTypeError{Loc: n.Loc, Op: string(n.Op), Actual: string(v.Type)}
+		}
+		r, err := negate(v)
+		if err != nil {
+			return Value{}, &ArithmeticError{Loc: n.Loc, Op: string(n.Op), Err: err}
+		}
+		return r, nil
+	case *BinaryOp:
+		if !n.Op.IsArithmetic() && !n.Op.IsComparison() {
+			break
+		}
+		a, err := in.evalValue(ctx, evaluator, n.X)
+		if err != nil {
+			return Value{}, err
+		}
+		b, err := in.evalValue(ctx, evaluator, n.Y)
+		if err != nil {
+			return Value{}, err
+		}
+		if n.Op.IsComparison() {
+			if !isComparable(n.Op, a.Type, b.Type) {
+				return Value{}, &OperandTypeError{Loc: n.Loc, Op: string(n.Op), Actual: operandTypes(a.Type, b.Type)}
+			}
+			return BoolValue(compareValues(n.Op, a, b)), nil
+		}
+		if arithmeticType(a.Type, b.Type) == "" {
+			return Value{}, &OperandTypeError{Loc: n.Loc, Op: string(n.Op), Actual: operandTypes(a.Type, b.Type)}
+		}
+		r, err := evalArithmetic(n.Op, a, b)
+		if err != nil {
+			return Value{}, &ArithmeticError{Loc: n.Loc, Op: string(n.Op), Err: err}
+		}
+		return r, nil
+	case *Call:
+		fn, exists := in.functions.Lookup(n.Name)
+		if !exists {
+			return Value{}, &UnknownFunctionError{Loc: n.NameLoc, Name: n.Name}
+		}
+		args := make([]Value, len(n.Args))
+		for i, arg := range n.Args {
+			v, err := in.evalValue(ctx, evaluator, arg)
+			if err != nil {
+				return Value{}, err
+			}
+			args[i] = v
+		}
+		return callFunction(ctx, evaluator, fn, n, args, FunctionErrorAbort)
+	case *FieldTerm, *FieldRef:
+		return Value{}, xerrors.Errorf("Unsupported node %T", node)
+	}
+	r, err := in.evalBool(ctx, evaluator, node)
+	if err != nil {
+		return Value{}, err
+	}
+	return BoolValue(r), nil
+}
+
+func TestInterpreter(t *testing.T) {
+	texts := []string{"", "abc", "bcd", "1"}
+	queries := []string{
+		`("a" || "b") && NOT ("c" && "d")`,
+		`1 + 2 * 3 == 7 && 1.5 < 2`,
+		`10 / 0 == 1`,
+		`-(-9223372036854775808) > 0`,
+		`"a" < 1`,
+		`Len("abc") > 2 && HasPrefix("ab")`,
+		`Fail() || "a"`,
+		`Len(Len("a"))`,
+		`Len("a") + Len("bc")`,
+	}
+	for _, q := range benchmarkEvalQueries {
+		queries = append(queries, q.query)
+	}
+	// The interpreter must agree with the compiled program for BenchmarkInterpret to be a fair baseline
+	for _, query := range queries {
+		f, err := GenerateFilterFromString(query, WithFunctions(newTestFunctions(t)))
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		in := newInterpreter(f)
+		for _, text := range texts {
+			evaluator := &testEvaluator{text: text}
+			expected, expectedErr := f.Eval(evaluator)
+			actual, actualErr := f.evalWith(context.Background(), evaluator, in.eval)
+			if actual != expected || errorMessage(actualErr) != errorMessage(expectedErr) {
+				t.Errorf("%s on %q: expected (%v, %v) but (%v, %v)", query, text, expected, expectedErr, actual, actualErr)
+			}
+		}
+	}
+}
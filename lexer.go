@@ -0,0 +1,275 @@
+package suzuitoql
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenInt
+	tokenFloat
+	tokenString
+	tokenLParen
+	tokenRParen
+	tokenComma
+	tokenColon
+	tokenDot
+	tokenContains
+	tokenLAnd
+	tokenLOr
+	tokenMinus
+	tokenNot
+	tokenEq
+	tokenNe
+	tokenLt
+	tokenLe
+	tokenGt
+	tokenGe
+	tokenPlus
+	tokenStar
+	tokenSlash
+	tokenPercent
+)
+
+type token struct {
+	Kind tokenKind
+	Text string
+	Loc  Span
+}
+
+func (t *token) String() string {
+	if t.Kind == tokenEOF {
+		return "EOF"
+	}
+	return fmt.Sprintf("'%s'", t.Text)
+}
+
+type lexer struct {
+	src string
+	pos Pos
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{
+		src: src,
+		pos: Pos{Offset: 0, Line: 1, Column: 1},
+	}
+}
+
+func (l *lexer) peekByte(n int) byte {
+	if l.pos.Offset+n >= len(l.src) {
+		return 0
+	}
+	return l.src[l.pos.Offset+n]
+}
+
+func (l *lexer) advance() {
+	r, size := utf8.DecodeRuneInString(l.src[l.pos.Offset:])
+	l.pos.Offset += size
+	if r == '\n' {
+		l.pos.Line++
+		l.pos.Column = 1
+		return
+	}
+	l.pos.Column++
+}
+
+func (l *lexer) skipSpaces() {
+	for l.pos.Offset < len(l.src) {
+		switch l.src[l.pos.Offset] {
+		case ' ', '\t', '\r', '\n':
+			l.advance()
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) Next() (*token, error) {
+	l.skipSpaces()
+	start := l.pos
+	if start.Offset >= len(l.src) {
+		return &token{Kind: tokenEOF, Loc: Span{Start: start, End: start}}, nil
+	}
+	c := l.src[start.Offset]
+	var kind tokenKind
+	switch {
+	case c == '(':
+		l.advance()
+		kind = tokenLParen
+	case c == ')':
+		l.advance()
+		kind = tokenRParen
+	case c == ',':
+		l.advance()
+		kind = tokenComma
+	case c == ':':
+		l.advance()
+		kind = tokenColon
+	case c == '.':
+		l.advance()
+		kind = tokenDot
+	case c == '-':
+		l.advance()
+		kind = tokenMinus
+	case c == '+':
+		l.advance()
+		kind = tokenPlus
+	case c == '*':
+		l.advance()
+		kind = tokenStar
+	case c == '/':
+		l.advance()
+		kind = tokenSlash
+	case c == '%':
+		l.advance()
+		kind = tokenPercent
+	case c == '!' && l.peekByte(1) == '=':
+		l.advance()
+		l.advance()
+		kind = tokenNe
+	case c == '!':
+		l.advance()
+		kind = tokenNot
+	case c == '=' && l.peekByte(1) == '=':
+		l.advance()
+		l.advance()
+		kind = tokenEq
+	case c == '<':
+		l.advance()
+		kind = tokenLt
+		if l.peekByte(0) == '=' {
+			l.advance()
+			kind = tokenLe
+		}
+	case c == '>':
+		l.advance()
+		kind = tokenGt
+		if l.peekByte(0) == '=' {
+			l.advance()
+			kind = tokenGe
+		}
+	case c == '&' && l.peekByte(1) == '&':
+		l.advance()
+		l.advance()
+		kind = tokenLAnd
+	case c == '|' && l.peekByte(1) == '|':
+		l.advance()
+		l.advance()
+		kind = tokenLOr
+	case c == '"' || c == '\'' || c == '`':
+		if err := l.scanString(c); err != nil {
+			return nil, err
+		}
+		kind = tokenString
+	case isDigit(c):
+		kind = l.scanNumber()
+	case isIdentStart(c):
+		for l.pos.Offset < len(l.src) && isIdentPart(l.src[l.pos.Offset]) {
+			l.advance()
+		}
+		kind = tokenIdent
+		switch l.src[start.Offset:l.pos.Offset] {
+		case "NOT":
+			kind = tokenNot
+		case "contains":
+			kind = tokenContains
+		}
+	default:
+		l.advance()
+		return nil, &SyntaxError{
+			Loc: Span{Start: start, End: l.pos},
+			Msg: fmt.Sprintf("unexpected character '%s'", l.src[start.Offset:l.pos.Offset]),
+		}
+	}
+	return &token{
+		Kind: kind,
+		Text: l.src[start.Offset:l.pos.Offset],
+		Loc:  Span{Start: start, End: l.pos},
+	}, nil
+}
+
+// scanString scans a string literal quoted by quote.
+// Backslashes escape the next character except in raw strings quoted by '`'.
+func (l *lexer) scanString(quote byte) error {
+	start := l.pos
+	l.advance()
+	for l.pos.Offset < len(l.src) {
+		switch l.src[l.pos.Offset] {
+		case quote:
+			l.advance()
+			return nil
+		case '\\':
+			l.advance()
+			if quote == '`' {
+				break
+			}
+			if l.pos.Offset >= len(l.src) {
+				break
+			}
+			l.advance()
+		default:
+			l.advance()
+		}
+	}
+	return &SyntaxError{
+		Loc: Span{Start: start, End: l.pos},
+		Msg: "string literal not terminated",
+	}
+}
+
+// posAfter returns the position after s which starts at p.
+func posAfter(p Pos, s string) Pos {
+	l := lexer{src: s, pos: Pos{Offset: 0, Line: p.Line, Column: p.Column}}
+	for l.pos.Offset < len(s) {
+		l.advance()
+	}
+	l.pos.Offset += p.Offset
+	return l.pos
+}
+
+func (l *lexer) scanNumber() tokenKind {
+	kind := tokenInt
+	l.scanDigits()
+	if l.peekByte(0) == '.' && isDigit(l.peekByte(1)) {
+		kind = tokenFloat
+		l.advance()
+		l.scanDigits()
+	}
+	if c := l.peekByte(0); c == 'e' || c == 'E' {
+		n := 1
+		if c := l.peekByte(1); c == '+' || c == '-' {
+			n++
+		}
+		if isDigit(l.peekByte(n)) {
+			kind = tokenFloat
+			for i := 0; i < n; i++ {
+				l.advance()
+			}
+			l.scanDigits()
+		}
+	}
+	return kind
+}
+
+func (l *lexer) scanDigits() {
+	for isDigit(l.peekByte(0)) {
+		l.advance()
+	}
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
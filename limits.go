@@ -0,0 +1,160 @@
+package suzuitoql
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Limits restricts resources which a query can use. Zero means no limit.
+type Limits struct {
+	// MaxSourceBytes limits the length of a query in bytes.
+	MaxSourceBytes int
+	// MaxDepth limits the nesting of parentheses, unary operators and arguments of function calls.
+	// Chains of operators such as `a || b || c` are not nested.
+	MaxDepth int
+	// MaxElements limits the number of nodes of the syntax tree of a query.
+	MaxElements int
+	// MaxStringLiteralLength limits the length of each string literal in bytes.
+	MaxStringLiteralLength int
+	// MaxFunctionCalls limits the number of function calls per Eval.
+	MaxFunctionCalls int
+	// MaxEvalTime limits the time per Eval.
+	MaxEvalTime time.Duration
+}
+
+func WithLimits(l Limits) Option {
+	return func(o *options) {
+		o.limits = l
+	}
+}
+
+// SourceLimitError is returned when a query exceeds Limits.MaxSourceBytes.
+type SourceLimitError struct {
+	Loc Span
+	Max int
+}
+
+func (e *SourceLimitError) Error() string {
+	return fmt.Sprintf("%s: query is longer than %d bytes", e.Loc.Start, e.Max)
+}
+
+func (e *SourceLimitError) Span() Span { return e.Loc }
+
+// DepthLimitError is returned when a query exceeds Limits.MaxDepth.
+type DepthLimitError struct {
+	Loc Span
+	Max int
+}
+
+func (e *DepthLimitError) Error() string {
+	return fmt.Sprintf("%s: query is nested deeper than %d", e.Loc.Start, e.Max)
+}
+
+func (e *DepthLimitError) Span() Span { return e.Loc }
+
+// ElementLimitError is returned when a query exceeds Limits.MaxElements.
+// Loc is the first node over the limit.
+type ElementLimitError struct {
+	Loc Span
+	Max int
+}
+
+func (e *ElementLimitError) Error() string {
+	return fmt.Sprintf("%s: query has more than %d nodes", e.Loc.Start, e.Max)
+}
+
+func (e *ElementLimitError) Span() Span { return e.Loc }
+
+// StringLiteralLimitError is returned when a string literal exceeds Limits.MaxStringLiteralLength.
+type StringLiteralLimitError struct {
+	Loc Span
+	Max int
+}
+
+func (e *StringLiteralLimitError) Error() string {
+	return fmt.Sprintf("%s: string literal is longer than %d bytes", e.Loc.Start, e.Max)
+}
+
+func (e *StringLiteralLimitError) Span() Span { return e.Loc }
+
+// FunctionCallLimitError is returned when an evaluation exceeds Limits.MaxFunctionCalls.
+// Loc is the function call which exceeded the limit.
+type FunctionCallLimitError struct {
+	Loc Span
+	Max int
+}
+
+func (e *FunctionCallLimitError) Error() string {
+	return fmt.Sprintf("%s: evaluation calls functions more than %d times", e.Loc.Start, e.Max)
+}
+
+func (e *FunctionCallLimitError) Span() Span { return e.Loc }
+
+// EvalTimeLimitError is returned when an evaluation exceeds Limits.MaxEvalTime.
+// Loc is where the evaluation stopped.
+type EvalTimeLimitError struct {
+	Loc Span
+	Max time.Duration
+}
+
+func (e *EvalTimeLimitError) Error() string {
+	return fmt.Sprintf("%s: evaluation takes longer than %s", e.Loc.Start, e.Max)
+}
+
+func (e *EvalTimeLimitError) Unwrap() error { return context.DeadlineExceeded }
+
+func (e *EvalTimeLimitError) Span() Span { return e.Loc }
+
+// checkLimits checks the syntax tree of a query against l.
+func checkLimits(root Expr, l Limits) error {
+	if l.MaxDepth <= 0 && l.MaxElements <= 0 && l.MaxStringLiteralLength <= 0 {
+		return nil
+	}
+	nodes := 0
+	// depth is counted in the same way as the parser does, where parentheses are the necessary ones
+	var check func(node Expr, depth int) error
+	check = func(node Expr, depth int) error {
+		if l.MaxDepth > 0 && depth > l.MaxDepth {
+			return &DepthLimitError{Loc: node.Span(), Max: l.MaxDepth}
+		}
+		nodes++
+		if l.MaxElements > 0 && nodes > l.MaxElements {
+			return &ElementLimitError{Loc: node.Span(), Max: l.MaxElements}
+		}
+		switch n := node.(type) {
+		case *BinaryOp:
+			prec := precedence(n)
+			if err := check(n.X, depth+nesting(n.X, prec-1)); err != nil {
+				return err
+			}
+			return check(n.Y, depth+nesting(n.Y, prec))
+		case *UnaryOp:
+			return check(n.X, depth+1+nesting(n.X, precUnary-1))
+		case *Not:
+			return check(n.X, depth+1+nesting(n.X, precUnary-1))
+		case *Call:
+			for _, arg := range n.Args {
+				if err := check(arg, depth+1); err != nil {
+					return err
+				}
+			}
+		case *FieldTerm:
+			return check(n.Term, depth)
+		case *Literal:
+			if l.MaxStringLiteralLength > 0 && n.Value.Type == TypeString && len(n.Value.Str) > l.MaxStringLiteralLength {
+				return &StringLiteralLimitError{Loc: n.Loc, Max: l.MaxStringLiteralLength}
+			}
+		}
+		return nil
+	}
+	return check(root, 0)
+}
+
+// nesting returns 1 if node must be parenthesized as an operand which must have precedence higher than prec.
+func nesting(node Expr, prec int) int {
+	if precedence(node) > prec {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,139 @@
+package suzuitoql
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+func TestLimits(t *testing.T) {
+	functions := newTestFunctions(t)
+	if err := functions.Register(Function{
+		Name:   "Wait",
+		Params: []Type{},
+		Result: TypeBool,
+		CallContext: func(ctx context.Context, evaluator Evaluator, args []Value) (Value, error) {
+			<-ctx.Done()
+			return Value{}, ctx.Err()
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	chain := `"a"` + strings.Repeat(` || "a"`, 39)
+	testCases := []struct {
+		desc        string
+		query       string
+		limits      Limits
+		expectedErr string
+		errType     interface{}
+	}{
+		{
+			desc:        "MaxSourceBytes",
+			query:       `"ゴーシュ" || "セロ"`,
+			limits:      Limits{MaxSourceBytes: 13},
+			expectedErr: "1:6: query is longer than 13 bytes",
+			errType:     new(*SourceLimitError),
+		},
+		{
+			desc:   "MaxSourceBytes not exceeded",
+			query:  `"ゴーシュ"`,
+			limits: Limits{MaxSourceBytes: 14},
+		},
+		{
+			desc:        "MaxDepth of parentheses",
+			query:       `"a" && ("b" || ("c" && ("d")))`,
+			limits:      Limits{MaxDepth: 2},
+			expectedErr: "1:25: query is nested deeper than 2",
+			errType:     new(*DepthLimitError),
+		},
+		{
+			desc:        "MaxDepth of unary operators and calls",
+			query:       `!Len(-a)`,
+			limits:      Limits{MaxDepth: 2},
+			expectedErr: "1:7: query is nested deeper than 2",
+			errType:     new(*DepthLimitError),
+		},
+		{
+			desc:   "MaxDepth ignores chains of operators",
+			query:  chain + ` && 1 + 2 + 3 + 4 == 10`,
+			limits: Limits{MaxDepth: 1},
+		},
+		{
+			desc:        "MaxElements",
+			query:       `"a" || "b" || "c"`,
+			limits:      Limits{MaxElements: 4},
+			expectedErr: "1:15: query has more than 4 nodes",
+			errType:     new(*ElementLimitError),
+		},
+		{
+			desc:   "MaxElements not exceeded",
+			query:  `"a" || "b" || "c"`,
+			limits: Limits{MaxElements: 5},
+		},
+		{
+			desc:        "MaxStringLiteralLength",
+			query:       `"a" || Len("ゴーシュ") > 1`,
+			limits:      Limits{MaxStringLiteralLength: 9},
+			expectedErr: "1:12: string literal is longer than 9 bytes",
+			errType:     new(*StringLiteralLimitError),
+		},
+		{
+			desc:        "MaxFunctionCalls",
+			query:       `Len("a") + Len("b") + Len("c") == 3`,
+			limits:      Limits{MaxFunctionCalls: 2},
+			expectedErr: "1:23: evaluation calls functions more than 2 times",
+			errType:     new(*FunctionCallLimitError),
+		},
+		{
+			desc:        "MaxEvalTime",
+			query:       `"x" || Wait()`,
+			limits:      Limits{MaxEvalTime: time.Millisecond},
+			expectedErr: "1:8: evaluation takes longer than 1ms",
+			errType:     new(*EvalTimeLimitError),
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			err := func() error {
+				f, err := GenerateFilterFromString(tC.query, WithFunctions(functions), WithLimits(tC.limits))
+				if err != nil {
+					return err
+				}
+				_, err = f.Eval(&testEvaluator{text: "abc"})
+				return err
+			}()
+			if errorMessage(err) != tC.expectedErr && errorMessage(err) != "Cannot ParseExpr : "+tC.expectedErr {
+				t.Fatalf("expected error %q but %q", tC.expectedErr, errorMessage(err))
+			}
+			if tC.errType != nil && !xerrors.As(err, tC.errType) {
+				t.Errorf("expected %T but %T", tC.errType, err)
+			}
+		})
+	}
+}
+
+func TestLimitsOfNewFilter(t *testing.T) {
+	chain, err := ParseExpr(`"a"` + strings.Repeat(` || "a"`, 39))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFilter(chain, WithLimits(Limits{MaxDepth: 32})); err != nil {
+		t.Errorf("expected no errors on a chain of || but %v", err)
+	}
+	if _, err := NewFilter(chain, WithLimits(Limits{MaxElements: 78})); !xerrors.As(err, new(*ElementLimitError)) {
+		t.Errorf("expected *ElementLimitError but %v", err)
+	}
+	nested, err := ParseExpr(`!!!"a" && ("b" || "c")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFilter(nested, WithLimits(Limits{MaxDepth: 2})); !xerrors.As(err, new(*DepthLimitError)) {
+		t.Errorf("expected *DepthLimitError but %v", err)
+	}
+	if _, err := NewFilter(nested, WithLimits(Limits{MaxDepth: 3})); err != nil {
+		t.Errorf("expected no errors but %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package suzuitoql
+
+import (
+	"context"
+)
+
+// Match is the result of Filter.Match.
+type Match struct {
+	Result bool
+	// Terms are the terms and function calls which were true and made Result true, in evaluation order.
+	// Terms in operands of !, comparisons, arithmetic and function calls are not included.
+	Terms []MatchedTerm
+}
+
+// MatchedTerm is a term, a field term or a function call which was true.
+type MatchedTerm struct {
+	Node  Expr  // *Literal, *FieldTerm or *Call
+	Value Value // Value evaluated by the evaluator
+	// Ranges are where the evaluator found Value, if the evaluator is a Locator.
+	// For calls of Match, they are where the pattern matched, if the evaluator is a RegexpLocator.
+	// They are empty for field terms.
+	Ranges []Range
+}
+
+// Range is a range [Start, End) of bytes in the data evaluated by an evaluator.
+type Range struct {
+	Start int
+	End   int
+}
+
+// Locator is implemented by evaluators which can tell where they found terms.
+// Locate returns ranges of v in the data evaluated by the evaluator.
+type Locator interface {
+	Locate(v Value) []Range
+}
+
+// Match is Eval which also reports the terms which made the result true.
+// It is slower than Eval, so use it only when the terms are needed.
+func (f *Filter) Match(evaluator Evaluator) (*Match, error) {
+	return f.MatchContext(context.Background(), evaluator)
+}
+
+// MatchContext is Match which stops when ctx is done. See EvalContext.
+func (f *Filter) MatchContext(ctx context.Context, evaluator Evaluator) (*Match, error) {
+	var terms []MatchedTerm
+	result, err := f.evalWith(ctx, evaluator, func(ctx context.Context, evaluator Evaluator) (bool, error) {
+		var result bool
+		var err error
+		result, terms, err = f.prog.run(ctx, evaluator, true, nil)
+		return result, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Match{
+		Result: result,
+		Terms:  terms,
+	}, nil
+}
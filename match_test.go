@@ -0,0 +1,74 @@
+package suzuitoql
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func (e *testEvaluator) Locate(v Value) []Range {
+	if v.Type != TypeString {
+		return nil
+	}
+	if i := strings.Index(e.text, v.Str); i >= 0 {
+		return []Range{{Start: i, End: i + len(v.Str)}}
+	}
+	return nil
+}
+
+func (e *testEvaluator) LocateRegexp(re *regexp.Regexp) []Range {
+	r := []Range{}
+	for _, m := range re.FindAllStringIndex(e.text, -1) {
+		r = append(r, Range{Start: m[0], End: m[1]})
+	}
+	return r
+}
+
+func TestMatch(t *testing.T) {
+	testCases := []struct {
+		query    string
+		text     string
+		expected string
+	}{
+		{`"a"`, "xa", `true ["a"[1 2]]`},
+		{`"a" && "b"`, "ab", `true ["a"[0 1] "b"[1 2]]`},
+		{`"a" && "c"`, "ab", `false []`},
+		{`("a" && "c") || "b"`, "ab", `true ["b"[1 2]]`},
+		{`"a" || "b"`, "ab", `true ["a"[0 1]]`},
+		{`!"c" && "b"`, "ab", `true ["b"[1 2]]`},
+		{`!("a" && "c")`, "ab", `true []`},
+		{`HasPrefix("a") && Len("a") == 1`, "ab", `true [HasPrefix[]]`},
+		{`("a" && "b") == true`, "ab", `true []`},
+		{`Match("b+") && "a"`, "abbab", `true [Match[1 3,4 5] "a"[0 1]]`},
+		{`!Match("c") && Match("a")`, "abbab", `true [Match[0 1,3 4]]`},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.query, func(t *testing.T) {
+			f, err := GenerateFilterFromString(tC.query, WithFunctions(newTestFunctions(t)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := f.Match(&testEvaluator{text: tC.text})
+			if err != nil {
+				t.Fatal(err)
+			}
+			terms := []string{}
+			for _, term := range m.Terms {
+				name := term.Value.String()
+				if call, ok := term.Node.(*Call); ok {
+					name = call.Name
+				}
+				ranges := []string{}
+				for _, r := range term.Ranges {
+					ranges = append(ranges, fmt.Sprintf("%d %d", r.Start, r.End))
+				}
+				terms = append(terms, fmt.Sprintf("%s[%s]", name, strings.Join(ranges, ",")))
+			}
+			actual := fmt.Sprintf("%v [%s]", m.Result, strings.Join(terms, " "))
+			if actual != tC.expected {
+				t.Errorf("expected %s but %s", tC.expected, actual)
+			}
+		})
+	}
+}
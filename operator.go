@@ -0,0 +1,186 @@
+package suzuitoql
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+func isNumericType(t Type) bool {
+	return t == TypeInt || t == TypeFloat
+}
+
+// isComparable returns true if values of a and b can be compared by op.
+// int and float are compared as float. bool can be compared only by == and !=.
+// contains can be applied to values only if both are string. Fields are evaluated by the evaluator. See containsField.
+func isComparable(op Op, a, b Type) bool {
+	switch {
+	case op == OpContains:
+		return a == TypeString && b == TypeString
+	case isNumericType(a) && isNumericType(b):
+		return true
+	case a == TypeString && b == TypeString:
+		return true
+	case a == TypeBool && b == TypeBool:
+		return op == OpEq || op == OpNe
+	}
+	return false
+}
+
+func operandTypes(a, b Type) string {
+	return fmt.Sprintf("%s and %s", a, b)
+}
+
+// compareValues compares a and b by op. a and b must be comparable by op.
+func compareValues(op Op, a, b Value) bool {
+	if op == OpContains {
+		return strings.Contains(a.Str, b.Str)
+	}
+	var c int
+	switch {
+	case a.Type == TypeInt && b.Type == TypeInt:
+		c = compareOrdered(a.Int < b.Int, a.Int > b.Int)
+	case isNumericType(a.Type) && isNumericType(b.Type):
+		fa, fb := a.Float, b.Float
+		if a.Type == TypeInt {
+			fa = float64(a.Int)
+		}
+		if b.Type == TypeInt {
+			fb = float64(b.Int)
+		}
+		if fa != fb && !(fa < fb) && !(fa > fb) {
+			// NaN is not equal to nor ordered with anything
+			return op == OpNe
+		}
+		c = compareOrdered(fa < fb, fa > fb)
+	case a.Type == TypeString:
+		c = compareOrdered(a.Str < b.Str, a.Str > b.Str)
+	case a.Type == TypeBool:
+		c = compareOrdered(false, a.Bool != b.Bool)
+	}
+	switch op {
+	case OpEq:
+		return c == 0
+	case OpNe:
+		return c != 0
+	case OpLt:
+		return c < 0
+	case OpLe:
+		return c <= 0
+	case OpGt:
+		return c > 0
+	case OpGe:
+		return c >= 0
+	}
+	return false
+}
+
+func compareOrdered(less, greater bool) int {
+	if less {
+		return -1
+	}
+	if greater {
+		return 1
+	}
+	return 0
+}
+
+// arithmeticType returns the type of the result of arithmetic operators applied to a and b,
+// or "" if they are not numbers. The result is int if both of them are int, otherwise float.
+func arithmeticType(a, b Type) Type {
+	if !isNumericType(a) || !isNumericType(b) {
+		return ""
+	}
+	if a == TypeInt && b == TypeInt {
+		return TypeInt
+	}
+	return TypeFloat
+}
+
+// evalArithmetic returns a op b. a and b must be types which op can apply to.
+func evalArithmetic(op Op, a, b Value) (Value, error) {
+	if a.Type == TypeInt && b.Type == TypeInt {
+		return evalIntArithmetic(op, a.Int, b.Int)
+	}
+	fa, fb := a.Float, b.Float
+	if a.Type == TypeInt {
+		fa = float64(a.Int)
+	}
+	if b.Type == TypeInt {
+		fb = float64(b.Int)
+	}
+	var r float64
+	switch op {
+	case OpAdd:
+		r = fa + fb
+	case OpSub:
+		r = fa - fb
+	case OpMul:
+		r = fa * fb
+	case OpDiv:
+		if fb == 0 {
+			return Value{}, ErrDivisionByZero
+		}
+		r = fa / fb
+	case OpMod:
+		if fb == 0 {
+			return Value{}, ErrDivisionByZero
+		}
+		r = math.Mod(fa, fb)
+	}
+	if math.IsInf(r, 0) && !math.IsInf(fa, 0) && !math.IsInf(fb, 0) {
+		return Value{}, ErrOverflow
+	}
+	return FloatValue(r), nil
+}
+
+func evalIntArithmetic(op Op, a, b int64) (Value, error) {
+	var r int64
+	switch op {
+	case OpAdd:
+		r = a + b
+		if (r > a) != (b > 0) {
+			return Value{}, ErrOverflow
+		}
+	case OpSub:
+		r = a - b
+		if (r < a) != (b > 0) {
+			return Value{}, ErrOverflow
+		}
+	case OpMul:
+		if a == 0 || b == 0 {
+			return IntValue(0), nil
+		}
+		r = a * b
+		if r/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+			return Value{}, ErrOverflow
+		}
+	case OpDiv, OpMod:
+		if b == 0 {
+			return Value{}, ErrDivisionByZero
+		}
+		if a == math.MinInt64 && b == -1 {
+			if op == OpMod {
+				return IntValue(0), nil
+			}
+			return Value{}, ErrOverflow
+		}
+		if op == OpDiv {
+			r = a / b
+		} else {
+			r = a % b
+		}
+	}
+	return IntValue(r), nil
+}
+
+// negate returns -v. v must be int or float.
+func negate(v Value) (Value, error) {
+	if v.Type == TypeFloat {
+		return FloatValue(-v.Float), nil
+	}
+	if v.Int == math.MinInt64 {
+		return Value{}, ErrOverflow
+	}
+	return IntValue(-v.Int), nil
+}
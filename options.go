@@ -0,0 +1,46 @@
+package suzuitoql
+
+// Option configures a Filter.
+type Option func(*options)
+
+type options struct {
+	functions           *FunctionRegistry
+	functionErrorPolicy FunctionErrorPolicy
+	limits              Limits
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		functionErrorPolicy: FunctionErrorAbort,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// FunctionErrorPolicy decides what Filter.Eval does when a function returns an error.
+type FunctionErrorPolicy int
+
+const (
+	// FunctionErrorAbort makes Filter.Eval return a *FunctionCallError.
+	FunctionErrorAbort FunctionErrorPolicy = iota
+	// FunctionErrorAsFalse makes the nearest comparison or term containing the failed function call evaluate to false.
+	// For example, `Length() > 100 || "a"` evaluates `Length() > 100` to false if Length fails.
+	// Errors other than ones returned by functions, such as type errors of arguments, abort Filter.Eval.
+	FunctionErrorAsFalse
+)
+
+func WithFunctionErrorPolicy(p FunctionErrorPolicy) Option {
+	return func(o *options) {
+		o.functionErrorPolicy = p
+	}
+}
+
+// WithFunctions sets functions callable from the query.
+// Without it, the query cannot call any function.
+func WithFunctions(r *FunctionRegistry) Option {
+	return func(o *options) {
+		o.functions = r
+	}
+}
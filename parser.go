@@ -0,0 +1,476 @@
+package suzuitoql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseExpr parses a query and returns its syntax tree.
+//
+//	expr    = and { "||" and }
+//	and     = cmp { "&&" cmp }
+//	cmp     = add { ( "==" | "!=" | "<" | "<=" | ">" | ">=" | "contains" ) add }
+//	add     = mul { ( "+" | "-" ) mul }
+//	mul     = unary { ( "*" | "/" | "%" ) unary }
+//	unary   = ( "!" | "NOT" ) unary | "-" unary | primary
+//	primary = literal | call | field | path | "(" expr ")"
+//	call    = ident "(" [ expr { "," expr } ] ")"
+//	field   = path ":" ( string | [ "-" ] ( int | float ) )
+//	path    = ident { "." ident }
+//	literal = string | int | float | "true" | "false"
+func ParseExpr(src string) (Expr, error) {
+	return parseExpr(src, Limits{})
+}
+
+// parseExpr parses src within MaxSourceBytes and MaxDepth of l.
+// The other limits are checked by checkLimits.
+func parseExpr(src string, l Limits) (Expr, error) {
+	if l.MaxSourceBytes > 0 && len(src) > l.MaxSourceBytes {
+		start := posAfter(Pos{Line: 1, Column: 1}, src[:l.MaxSourceBytes])
+		return nil, &SourceLimitError{
+			Loc: Span{Start: start, End: posAfter(start, src[l.MaxSourceBytes:])},
+			Max: l.MaxSourceBytes,
+		}
+	}
+	p := parser{lexer: newLexer(src), maxDepth: l.MaxDepth}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.Kind != tokenEOF {
+		return nil, p.errorf("expected end of query, found %s", p.tok)
+	}
+	return e, nil
+}
+
+type parser struct {
+	lexer    *lexer
+	tok      *token
+	depth    int
+	maxDepth int
+}
+
+// enter is called before parsing a nested expression. leave must be called after it.
+func (p *parser) enter() error {
+	p.depth++
+	if p.maxDepth > 0 && p.depth > p.maxDepth {
+		return &DepthLimitError{Loc: p.tok.Loc, Max: p.maxDepth}
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func (p *parser) next() error {
+	tok, err := p.lexer.Next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{
+		Loc: p.tok.Loc,
+		Msg: fmt.Sprintf(format, args...),
+	}
+}
+
+func (p *parser) expect(kind tokenKind, what string) (*token, error) {
+	tok := p.tok
+	if tok.Kind != kind {
+		return nil, p.errorf("expected %s, found %s", what, tok)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	return tok, nil
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.Kind == tokenLOr {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		y, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		x = &BinaryOp{
+			Loc: Span{Start: x.Span().Start, End: y.Span().End},
+			Op:  OpOr,
+			X:   x,
+			Y:   y,
+		}
+	}
+	return x, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	x, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.Kind == tokenLAnd {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		y, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		x = &BinaryOp{
+			Loc: Span{Start: x.Span().Start, End: y.Span().End},
+			Op:  OpAnd,
+			X:   x,
+			Y:   y,
+		}
+	}
+	return x, nil
+}
+
+var comparisonOps = map[tokenKind]Op{
+	tokenEq:       OpEq,
+	tokenNe:       OpNe,
+	tokenLt:       OpLt,
+	tokenLe:       OpLe,
+	tokenGt:       OpGt,
+	tokenGe:       OpGe,
+	tokenContains: OpContains,
+}
+
+var additiveOps = map[tokenKind]Op{
+	tokenPlus:  OpAdd,
+	tokenMinus: OpSub,
+}
+
+var multiplicativeOps = map[tokenKind]Op{
+	tokenStar:    OpMul,
+	tokenSlash:   OpDiv,
+	tokenPercent: OpMod,
+}
+
+func (p *parser) parseComparison() (Expr, error) {
+	return p.parseBinary(comparisonOps, p.parseAdditive)
+}
+
+func (p *parser) parseAdditive() (Expr, error) {
+	return p.parseBinary(additiveOps, p.parseMultiplicative)
+}
+
+func (p *parser) parseMultiplicative() (Expr, error) {
+	return p.parseBinary(multiplicativeOps, p.parseUnary)
+}
+
+// parseBinary parses left-associative binary operations of ops whose operands are parsed by operand.
+func (p *parser) parseBinary(ops map[tokenKind]Op, operand func() (Expr, error)) (Expr, error) {
+	x, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := ops[p.tok.Kind]
+		if !ok {
+			return x, nil
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		y, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		x = &BinaryOp{
+			Loc: Span{Start: x.Span().Start, End: y.Span().End},
+			Op:  op,
+			X:   x,
+			Y:   y,
+		}
+	}
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	kind := p.tok.Kind
+	if kind != tokenMinus && kind != tokenNot {
+		return p.parsePrimary()
+	}
+	minus := p.tok
+	start := minus.Loc.Start
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if kind == tokenMinus && (p.tok.Kind == tokenInt || p.tok.Kind == tokenFloat) {
+		return p.parseNegativeLiteral(minus)
+	}
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	x, err := p.parseUnary()
+	p.leave()
+	if err != nil {
+		return nil, err
+	}
+	if kind == tokenNot {
+		return &Not{
+			Loc: Span{Start: start, End: x.Span().End},
+			X:   x,
+		}, nil
+	}
+	return &UnaryOp{
+		Loc: Span{Start: start, End: x.Span().End},
+		Op:  OpMinus,
+		X:   x,
+	}, nil
+}
+
+// parseNegativeLiteral parses a number after minus as a negative literal.
+// The sign is a part of the literal, so that the smallest int can be written.
+func (p *parser) parseNegativeLiteral(minus *token) (*Literal, error) {
+	lit, err := newLiteral(&token{
+		Kind: p.tok.Kind,
+		Text: "-" + p.tok.Text,
+		Loc:  Span{Start: minus.Loc.Start, End: p.tok.Loc.End},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	return lit, nil
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	tok := p.tok
+	switch tok.Kind {
+	case tokenLParen:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseOr()
+		p.leave()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return x, nil
+	case tokenString, tokenInt, tokenFloat:
+		lit, err := newLiteral(tok)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		return lit, nil
+	case tokenIdent:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if tok.Text == "true" || tok.Text == "false" {
+			return &Literal{
+				Loc:   tok.Loc,
+				Raw:   tok.Text,
+				Value: BoolValue(tok.Text == "true"),
+			}, nil
+		}
+		if p.tok.Kind == tokenLParen {
+			return p.parseCall(tok)
+		}
+		return p.parsePath(tok)
+	}
+	return nil, p.errorf("expected operand, found %s", tok)
+}
+
+func (p *parser) parseCall(name *token) (Expr, error) {
+	if _, err := p.expect(tokenLParen, fmt.Sprintf("'(' after %s", name.Text)); err != nil {
+		return nil, err
+	}
+	args := []Expr{}
+	for p.tok.Kind != tokenRParen {
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		arg, err := p.parseOr()
+		p.leave()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.tok.Kind != tokenComma {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	rparen, err := p.expect(tokenRParen, "')' or ','")
+	if err != nil {
+		return nil, err
+	}
+	return &Call{
+		Loc:     Span{Start: name.Loc.Start, End: rparen.Loc.End},
+		Name:    name.Text,
+		NameLoc: name.Loc,
+		Args:    args,
+	}, nil
+}
+
+// parsePath parses a path starting with first, which is a field reference or the field of a field term.
+func (p *parser) parsePath(first *token) (Expr, error) {
+	path := []string{first.Text}
+	loc := first.Loc
+	for p.tok.Kind == tokenDot {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.expect(tokenIdent, "field name after '.'")
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, name.Text)
+		loc.End = name.Loc.End
+	}
+	if p.tok.Kind == tokenColon {
+		return p.parseFieldTerm(&token{
+			Kind: tokenIdent,
+			Text: strings.Join(path, "."),
+			Loc:  loc,
+		})
+	}
+	return &FieldRef{
+		Loc:  loc,
+		Path: path,
+	}, nil
+}
+
+func (p *parser) parseFieldTerm(field *token) (Expr, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	tok := p.tok
+	if tok.Kind == tokenMinus {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.tok.Kind != tokenInt && p.tok.Kind != tokenFloat {
+			return nil, p.errorf("expected number after '-', found %s", p.tok)
+		}
+		term, err := p.parseNegativeLiteral(tok)
+		if err != nil {
+			return nil, err
+		}
+		return newFieldTerm(field, term), nil
+	}
+	if tok.Kind != tokenString && tok.Kind != tokenInt && tok.Kind != tokenFloat {
+		return nil, p.errorf("expected term after '%s:', found %s", field.Text, tok)
+	}
+	term, err := newLiteral(tok)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	return newFieldTerm(field, term), nil
+}
+
+func newFieldTerm(field *token, term *Literal) *FieldTerm {
+	return &FieldTerm{
+		Loc:      Span{Start: field.Loc.Start, End: term.Loc.End},
+		Field:    field.Text,
+		FieldLoc: field.Loc,
+		Term:     term,
+	}
+}
+
+func newLiteral(tok *token) (*Literal, error) {
+	lit := Literal{
+		Loc: tok.Loc,
+		Raw: tok.Text,
+	}
+	switch tok.Kind {
+	case tokenString:
+		v, err := unquoteString(tok)
+		if err != nil {
+			return nil, err
+		}
+		lit.Value = StringValue(v)
+	case tokenInt:
+		v, err := strconv.ParseInt(tok.Text, 10, 64)
+		if err != nil {
+			return nil, &SyntaxError{Loc: tok.Loc, Msg: fmt.Sprintf("invalid int literal %s", tok.Text)}
+		}
+		lit.Value = IntValue(v)
+	case tokenFloat:
+		v, err := strconv.ParseFloat(tok.Text, 64)
+		if err != nil {
+			return nil, &SyntaxError{Loc: tok.Loc, Msg: fmt.Sprintf("invalid float literal %s", tok.Text)}
+		}
+		lit.Value = FloatValue(v)
+	default:
+		return nil, &SyntaxError{Loc: tok.Loc, Msg: fmt.Sprintf("expected literal, found %s", tok)}
+	}
+	return &lit, nil
+}
+
+// unquoteString returns the value of a string literal.
+// Escape sequences are the same as Go's, except that both \" and \' are allowed
+// in both quotes. Raw strings quoted by '`' have no escape sequences.
+func unquoteString(tok *token) (string, error) {
+	quote := tok.Text[0]
+	body := tok.Text[1 : len(tok.Text)-1]
+	if quote == '`' {
+		return body, nil
+	}
+	b := strings.Builder{}
+	for i := 0; i < len(body); {
+		if body[i] != '\\' {
+			j := strings.IndexByte(body[i:], '\\')
+			if j < 0 {
+				j = len(body) - i
+			}
+			b.WriteString(body[i : i+j])
+			i += j
+			continue
+		}
+		if i+1 < len(body) && (body[i+1] == '"' || body[i+1] == '\'') {
+			b.WriteByte(body[i+1])
+			i += 2
+			continue
+		}
+		value, multibyte, tail, err := strconv.UnquoteChar(body[i:], quote)
+		if err != nil {
+			start := posAfter(tok.Loc.Start, tok.Text[:1+i])
+			_, size := utf8.DecodeRuneInString(body[i+1:])
+			return "", &SyntaxError{
+				Loc: Span{Start: start, End: posAfter(start, body[i:i+1+size])},
+				Msg: "invalid escape sequence in string literal",
+			}
+		}
+		if value < utf8.RuneSelf || !multibyte {
+			b.WriteByte(byte(value))
+		} else {
+			b.WriteRune(value)
+		}
+		i = len(body) - len(tail)
+	}
+	return b.String(), nil
+}
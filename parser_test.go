@@ -0,0 +1,99 @@
+package suzuitoql
+
+import (
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+func TestUnquoteString(t *testing.T) {
+	testCases := []struct {
+		query       string
+		expected    string
+		expectedErr string
+		// expectedSpan is the span of the error as "line:column-line:column"
+		expectedSpan string
+	}{
+		{query: `"say \"hi\""`, expected: `say "hi"`},
+		{query: `'say \"hi\"'`, expected: `say "hi"`},
+		{query: `"it\'s"`, expected: `it's`},
+		{query: `'it\'s'`, expected: `it's`},
+		{query: `"tab\there\\"`, expected: "tab\there\\"},
+		{query: `"セロ \U0001F3BB"`, expected: "セロ 🎻"},
+		{query: `"\x41\x42 \101"`, expected: "AB A"},
+		{query: `"\xe3\x82\xbb"`, expected: "セ"},
+		{query: "`raw \\n \\\" string`", expected: `raw \n \" string`},
+		{query: "`multi\nline`", expected: "multi\nline"},
+		{query: "\"セロ\n弾き\"", expected: "セロ\n弾き"},
+		{query: `"ゴーシュ\q"`, expectedErr: "1:6: invalid escape sequence in string literal", expectedSpan: "1:6-1:8"},
+		{query: `'\u30'`, expectedErr: "1:2: invalid escape sequence in string literal", expectedSpan: "1:2-1:4"},
+		{query: `"\xZZ"`, expectedErr: "1:2: invalid escape sequence in string literal", expectedSpan: "1:2-1:4"},
+		{query: `"\セロ"`, expectedErr: "1:2: invalid escape sequence in string literal", expectedSpan: "1:2-1:4"},
+		{query: "\"セロ\n弾き\\z\"", expectedErr: "2:3: invalid escape sequence in string literal", expectedSpan: "2:3-2:5"},
+		{query: `"ゴーシュ`, expectedErr: "1:1: string literal not terminated", expectedSpan: "1:1-1:6"},
+		{query: `"ゴーシュ\"`, expectedErr: "1:1: string literal not terminated", expectedSpan: "1:1-1:8"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.query, func(t *testing.T) {
+			root, err := ParseExpr(tC.query)
+			if tC.expectedErr != "" {
+				var serr spanError
+				if err == nil || err.Error() != tC.expectedErr || !xerrors.As(err, &serr) {
+					t.Fatalf("expected error %q but %v", tC.expectedErr, err)
+				}
+				s := serr.Span()
+				if actual := s.Start.String() + "-" + s.End.String(); actual != tC.expectedSpan {
+					t.Errorf("expected span %s but %s", tC.expectedSpan, actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			lit, ok := root.(*Literal)
+			if !ok || lit.Value.Type != TypeString {
+				t.Fatalf("expected a string literal but %#v", root)
+			}
+			if lit.Value.Str != tC.expected {
+				t.Errorf("expected %q but %q", tC.expected, lit.Value.Str)
+			}
+			if lit.Raw != tC.query {
+				t.Errorf("expected raw %q but %q", tC.query, lit.Raw)
+			}
+		})
+	}
+}
+
+func TestParseNegativeLiterals(t *testing.T) {
+	testCases := []struct {
+		query       string
+		expected    string
+		expectedErr string
+	}{
+		{query: `-9223372036854775808`, expected: `int(-9223372036854775808)`},
+		{query: `- 1.5`, expected: `float(-1.5)`},
+		{query: `--1`, expected: `(- int(-1))`},
+		{query: `-(1)`, expected: `(- int(1))`},
+		{query: `2 - -1 * 3`, expected: `(- int(2) (* int(-1) int(3)))`},
+		{query: `-a`, expected: `(- a)`},
+		{query: `-9223372036854775809`, expectedErr: "1:1: invalid int literal -9223372036854775809"},
+		{query: `9223372036854775808`, expectedErr: "1:1: invalid int literal 9223372036854775808"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.query, func(t *testing.T) {
+			root, err := ParseExpr(tC.query)
+			if tC.expectedErr != "" {
+				if err == nil || err.Error() != tC.expectedErr {
+					t.Errorf("expected error %q but %v", tC.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := treeOf(root); actual != tC.expected {
+				t.Errorf("expected %s but %s", tC.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package suzuitoql
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"golang.org/x/xerrors"
+)
+
+// matchFunctionName is the name of the built-in function `Match(pattern)`, which is true
+// if the data evaluated by the evaluator matches the regular expression pattern.
+// pattern must be a string literal in RE2 syntax, which is compiled when the filter is generated.
+const matchFunctionName = "Match"
+
+// RegexpEvaluator is implemented by evaluators which can evaluate Match.
+type RegexpEvaluator interface {
+	EvalRegexp(re *regexp.Regexp) (result bool, err error)
+}
+
+// RegexpLocator is implemented by evaluators which can tell where Match matched.
+// LocateRegexp returns ranges of the leftmost non-overlapping matches of re in the data evaluated by the evaluator.
+type RegexpLocator interface {
+	LocateRegexp(re *regexp.Regexp) []Range
+}
+
+// RegexpError is returned when a pattern of Match is invalid.
+type RegexpError struct {
+	Loc     Span
+	Pattern string
+	Err     error
+}
+
+func (e *RegexpError) Error() string {
+	return fmt.Sprintf("%s: invalid regular expression %q: %s", e.Loc.Start, e.Pattern, e.Err)
+}
+
+func (e *RegexpError) Unwrap() error { return e.Err }
+
+func (e *RegexpError) Span() Span { return e.Loc }
+
+// compileRegexps compiles patterns of all Match calls in root.
+func compileRegexps(root Expr) (map[*Call]*regexp.Regexp, error) {
+	regexps := map[*Call]*regexp.Regexp{}
+	var err error
+	Inspect(root, func(node Expr) bool {
+		call, ok := node.(*Call)
+		if !ok || call.Name != matchFunctionName || err != nil {
+			return err == nil
+		}
+		var re *regexp.Regexp
+		re, err = compileMatch(call)
+		if err != nil {
+			return false
+		}
+		regexps[call] = re
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return regexps, nil
+}
+
+// compileMatch compiles the pattern of call of Match.
+func compileMatch(call *Call) (*regexp.Regexp, error) {
+	if len(call.Args) != 1 {
+		return nil, &ArgumentCountError{
+			Loc:      call.Loc,
+			Func:     call.Name,
+			Expected: 1,
+			Actual:   len(call.Args),
+		}
+	}
+	literal, ok := call.Args[0].(*Literal)
+	if !ok || literal.Value.Type != TypeString {
+		return nil, &SyntaxError{
+			Loc: call.Args[0].Span(),
+			Msg: "pattern of Match must be a string literal",
+		}
+	}
+	re, err := regexp.Compile(literal.Value.Str)
+	if err != nil {
+		return nil, &RegexpError{
+			Loc:     literal.Loc,
+			Pattern: literal.Value.Str,
+			Err:     err,
+		}
+	}
+	return re, nil
+}
+
+// callMatch evaluates Match by the evaluator.
+func callMatch(ctx context.Context, evaluator Evaluator, call *Call, re *regexp.Regexp, policy FunctionErrorPolicy) (Value, error) {
+	newCallError := func(err error) error {
+		return &FunctionCallError{
+			Loc:  call.Loc,
+			Func: call.Name,
+			Args: []Value{StringValue(re.String())},
+			Err:  err,
+		}
+	}
+	e, ok := evaluator.(RegexpEvaluator)
+	if !ok {
+		return Value{}, newCallError(xerrors.Errorf("Evaluator %T does not implement RegexpEvaluator", evaluator))
+	}
+	r, err := e.EvalRegexp(re)
+	if err != nil {
+		if ctx.Err() != nil {
+			return Value{}, &CanceledError{
+				Loc: call.Loc,
+				Err: ctx.Err(),
+			}
+		}
+		if policy == FunctionErrorAsFalse {
+			return Value{}, &falseError{node: call, err: newCallError(err)}
+		}
+		return Value{}, newCallError(err)
+	}
+	return BoolValue(r), nil
+}
@@ -0,0 +1,52 @@
+package suzuitoql
+
+import (
+	"regexp"
+	"testing"
+)
+
+func (e *testEvaluator) EvalRegexp(re *regexp.Regexp) (bool, error) {
+	return re.MatchString(e.text), nil
+}
+
+func TestMatchFunction(t *testing.T) {
+	testCases := []struct {
+		query       string
+		text        string
+		expected    bool
+		expectedErr string
+	}{
+		{query: `Match("^ゴー?シュ")`, text: "ゴシュ", expected: true},
+		{query: `Match("^ゴー?シュ")`, text: "セロ弾きのゴーシュ", expected: false},
+		{query: `"セロ" && !Match("[0-9]+")`, text: "セロ", expected: true},
+		{query: `Match("(")`, expectedErr: "1:7: invalid regular expression \"(\": error parsing regexp: missing closing ): `(`"},
+		{query: `"a" || Match("a", "b")`, expectedErr: "1:8: function 'Match' takes 1 argument(s) but 2 given"},
+		{query: `Match("a" + "b")`, expectedErr: "1:7: pattern of Match must be a string literal"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.query, func(t *testing.T) {
+			f, err := GenerateFilterFromString(tC.query)
+			if err != nil {
+				if errorMessage(err) != tC.expectedErr {
+					t.Errorf("expected error %q but %q", tC.expectedErr, errorMessage(err))
+				}
+				return
+			}
+			if tC.expectedErr != "" {
+				t.Fatalf("expected error %q but nil", tC.expectedErr)
+			}
+			evaluator := &testEvaluator{text: tC.text}
+			actual, err := f.Eval(evaluator)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e, err := f.Explain(evaluator)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tC.expected || e.Result != tC.expected {
+				t.Errorf("expected %v but %v, explained %v", tC.expected, actual, e.Result)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package suzuitoql
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Type is a type of values in suzuitoql.
+type Type string
+
+const (
+	TypeString Type = "string"
+	TypeInt    Type = "int"
+	TypeFloat  Type = "float"
+	TypeBool   Type = "bool"
+)
+
+// Value is a value of suzuitoql. Only the field corresponding to Type is meaningful.
+type Value struct {
+	Type  Type
+	Str   string
+	Int   int64
+	Float float64
+	Bool  bool
+}
+
+func StringValue(v string) Value {
+	return Value{Type: TypeString, Str: v}
+}
+
+func IntValue(v int64) Value {
+	return Value{Type: TypeInt, Int: v}
+}
+
+func FloatValue(v float64) Value {
+	return Value{Type: TypeFloat, Float: v}
+}
+
+func BoolValue(v bool) Value {
+	return Value{Type: TypeBool, Bool: v}
+}
+
+func (v Value) String() string {
+	switch v.Type {
+	case TypeString:
+		return strconv.Quote(v.Str)
+	case TypeInt:
+		return strconv.FormatInt(v.Int, 10)
+	case TypeFloat:
+		return strconv.FormatFloat(v.Float, 'g', -1, 64)
+	case TypeBool:
+		return strconv.FormatBool(v.Bool)
+	}
+	return fmt.Sprintf("<invalid value of type '%s'>", v.Type)
+}
@@ -0,0 +1,153 @@
+package suzuitoql
+
+import (
+	"fmt"
+	"reflect"
+
+	"golang.org/x/xerrors"
+)
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children of node
+// with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Expr) (w Visitor)
+}
+
+// Walk traverses a syntax tree in depth-first order in the same way as go/ast.Walk.
+func Walk(v Visitor, node Expr) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	for _, child := range children(node) {
+		Walk(v, child)
+	}
+	v.Visit(nil)
+}
+
+type inspector func(Expr) bool
+
+func (f inspector) Visit(node Expr) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a syntax tree in depth-first order. It calls f(node) for each node,
+// and then f(nil) after the children of node if f(node) returns true.
+func Inspect(node Expr, f func(Expr) bool) {
+	Walk(inspector(f), node)
+}
+
+func children(node Expr) []Expr {
+	switch n := node.(type) {
+	case *BinaryOp:
+		return []Expr{n.X, n.Y}
+	case *UnaryOp:
+		return []Expr{n.X}
+	case *Not:
+		return []Expr{n.X}
+	case *Call:
+		return n.Args
+	case *FieldTerm:
+		return []Expr{n.Term}
+	}
+	return nil
+}
+
+// Rewrite returns a copy of a syntax tree whose each node is replaced by f.
+// f is called in depth-first post order, with a copy of the node whose children are already rewritten.
+// If f returns an error, Rewrite stops and returns it.
+// The original tree is not modified.
+func Rewrite(node Expr, f func(Expr) (Expr, error)) (Expr, error) {
+	var r Expr
+	switch n := node.(type) {
+	case *BinaryOp:
+		x, err := Rewrite(n.X, f)
+		if err != nil {
+			return nil, err
+		}
+		y, err := Rewrite(n.Y, f)
+		if err != nil {
+			return nil, err
+		}
+		c := *n
+		c.X, c.Y = x, y
+		r = &c
+	case *UnaryOp:
+		x, err := Rewrite(n.X, f)
+		if err != nil {
+			return nil, err
+		}
+		c := *n
+		c.X = x
+		r = &c
+	case *Not:
+		x, err := Rewrite(n.X, f)
+		if err != nil {
+			return nil, err
+		}
+		c := *n
+		c.X = x
+		r = &c
+	case *Call:
+		c := *n
+		c.Args = make([]Expr, len(n.Args))
+		for i, arg := range n.Args {
+			a, err := Rewrite(arg, f)
+			if err != nil {
+				return nil, err
+			}
+			c.Args[i] = a
+		}
+		r = &c
+	case *FieldTerm:
+		t, err := Rewrite(n.Term, f)
+		if err != nil {
+			return nil, err
+		}
+		term, ok := t.(*Literal)
+		if !ok {
+			return nil, &SyntaxError{Loc: t.Span(), Msg: fmt.Sprintf("term of field '%s' must be a literal", n.Field)}
+		}
+		c := *n
+		c.Term = term
+		r = &c
+	case *FieldRef:
+		c := *n
+		c.Path = append([]string{}, n.Path...)
+		r = &c
+	case *Literal:
+		c := *n
+		r = &c
+	default:
+		return nil, xerrors.Errorf("Unsupported node %T", node)
+	}
+	return f(r)
+}
+
+// validateExpr checks that a syntax tree built or rewritten by hand has no nil nodes.
+func validateExpr(node Expr) error {
+	var err error
+	if isNilExpr(node) {
+		return xerrors.New("Root node is nil")
+	}
+	Inspect(node, func(n Expr) bool {
+		if err != nil || n == nil {
+			return false
+		}
+		for _, child := range children(n) {
+			if isNilExpr(child) {
+				err = &SyntaxError{Loc: n.Span(), Msg: "node has a nil child"}
+				return false
+			}
+		}
+		return true
+	})
+	return err
+}
+
+func isNilExpr(node Expr) bool {
+	return node == nil || reflect.ValueOf(node).IsNil()
+}
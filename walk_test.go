@@ -0,0 +1,192 @@
+package suzuitoql
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+// recorder records nodes visited by Walk. It does not visit children of calls.
+type recorder struct {
+	visited *[]string
+}
+
+func (r recorder) Visit(node Expr) Visitor {
+	if node == nil {
+		*r.visited = append(*r.visited, "end")
+		return nil
+	}
+	*r.visited = append(*r.visited, FormatExpr(node, FormatOptions{}))
+	if _, ok := node.(*Call); ok {
+		return nil
+	}
+	return r
+}
+
+func TestWalk(t *testing.T) {
+	root, err := ParseExpr(`!"a" || Len("b", 1) > year:1934`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	visited := []string{}
+	Walk(recorder{visited: &visited}, root)
+	expected := []string{
+		`!"a" || Len("b", 1) > year:1934`,
+		`!"a"`,
+		`"a"`,
+		"end",
+		"end",
+		`Len("b", 1) > year:1934`,
+		`Len("b", 1)`,
+		`year:1934`,
+		`1934`,
+		"end",
+		"end",
+		"end",
+		"end",
+	}
+	if strings.Join(visited, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected\n%s\nbut\n%s", strings.Join(expected, "\n"), strings.Join(visited, "\n"))
+	}
+}
+
+func TestInspect(t *testing.T) {
+	root, err := ParseExpr(`"a" && (Len("bc") > 1 || !Match("d"))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	literals := []string{}
+	ends := 0
+	Inspect(root, func(node Expr) bool {
+		switch n := node.(type) {
+		case nil:
+			ends++
+		case *Literal:
+			literals = append(literals, n.Raw)
+		case *Not:
+			// Match and its argument are skipped
+			return false
+		}
+		return true
+	})
+	if actual := strings.Join(literals, ","); actual != `"a","bc",1` {
+		t.Errorf("expected literals \"a\",\"bc\",1 but %s", actual)
+	}
+	// f(nil) is called for each node whose f(node) returned true
+	if ends != 7 {
+		t.Errorf("expected 7 calls of f(nil) but %d", ends)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	query := `"a" && Len("a") > 1 || title:"a"`
+	root, err := ParseExpr(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		desc        string
+		f           func(node Expr) (Expr, error)
+		expected    string
+		expectedErr string
+	}{
+		{
+			desc: "replace literals",
+			f: func(node Expr) (Expr, error) {
+				if lit, ok := node.(*Literal); ok && lit.Value.Type == TypeString {
+					lit.Value = StringValue(strings.ToUpper(lit.Value.Str))
+				}
+				return node, nil
+			},
+			expected: `"A" && Len("A") > 1 || title:"A"`,
+		},
+		{
+			desc: "replace calls by their values",
+			f: func(node Expr) (Expr, error) {
+				if call, ok := node.(*Call); ok && call.Name == "Len" {
+					arg := call.Args[0].(*Literal)
+					return &Literal{Loc: call.Loc, Value: IntValue(int64(len(arg.Value.Str)))}, nil
+				}
+				return node, nil
+			},
+			expected: `"a" && 1 > 1 || title:"a"`,
+		},
+		{
+			desc: "stop at an error",
+			f: func(node Expr) (Expr, error) {
+				if _, ok := node.(*Call); ok {
+					return nil, xerrors.New("calls are not allowed")
+				}
+				return node, nil
+			},
+			expectedErr: "calls are not allowed",
+		},
+		{
+			desc: "reject non-literal terms of fields",
+			f: func(node Expr) (Expr, error) {
+				if lit, ok := node.(*Literal); ok && lit.Loc.Start.Column == 30 {
+					return &Call{Loc: lit.Loc, Name: "Lower", Args: []Expr{lit}}, nil
+				}
+				return node, nil
+			},
+			expectedErr: "1:30: term of field 'title' must be a literal",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			rewritten, err := Rewrite(root, tC.f)
+			actualErr := ""
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if actualErr != tC.expectedErr {
+				t.Fatalf("expected error %q but %q", tC.expectedErr, actualErr)
+			}
+			if err == nil {
+				if actual := FormatExpr(rewritten, FormatOptions{}); actual != tC.expected {
+					t.Errorf("expected %s but %s", tC.expected, actual)
+				}
+				if _, err := NewFilter(rewritten, WithFunctions(newTestFunctions(t))); err != nil {
+					t.Error(err)
+				}
+			}
+			if actual := FormatExpr(root, FormatOptions{}); actual != `"a" && Len("a") > 1 || title:"a"` {
+				t.Errorf("the original tree is modified into %s", actual)
+			}
+		})
+	}
+}
+
+func TestNewFilter(t *testing.T) {
+	a := &Literal{Loc: Span{Start: Pos{Offset: 0, Line: 1, Column: 1}}, Raw: `"a"`, Value: StringValue("a")}
+	testCases := []struct {
+		desc        string
+		root        Expr
+		expected    bool
+		expectedErr string
+	}{
+		{desc: "valid", root: &BinaryOp{Op: OpOr, X: &Not{X: a}, Y: a}, expected: true},
+		{desc: "nil root", root: nil, expectedErr: "Root node is nil"},
+		{desc: "typed nil root", root: (*Not)(nil), expectedErr: "Root node is nil"},
+		{desc: "nil child", root: &BinaryOp{Loc: a.Loc, Op: OpAnd, X: a}, expectedErr: "1:1: node has a nil child"},
+		{desc: "typed nil child", root: &Not{X: &UnaryOp{Op: OpMinus, X: (*Literal)(nil)}}, expectedErr: "0:0: node has a nil child"},
+		{desc: "nil argument", root: &BinaryOp{Op: OpOr, X: a, Y: &Call{Name: "Len", Args: []Expr{a, nil}}}, expectedErr: "0:0: node has a nil child"},
+		{desc: "unknown operator", root: &BinaryOp{Op: "=~", X: a, Y: a}, expectedErr: "Unsupported node *suzuitoql.BinaryOp"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			f, err := NewFilter(tC.root)
+			if err == nil {
+				var r bool
+				r, err = f.Eval(&testEvaluator{text: "b"})
+				if err == nil && r != tC.expected {
+					t.Errorf("expected %v but %v", tC.expected, r)
+				}
+			}
+			if errorMessage(err) != tC.expectedErr {
+				t.Errorf("expected error %q but %q", tC.expectedErr, errorMessage(err))
+			}
+		})
+	}
+}